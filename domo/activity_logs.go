@@ -9,20 +9,20 @@ import (
 
 // LogEntry describes a single event recorded in Domo's Activity/Audit Log.
 type LogEntry struct {
-	UserName          string `json:"userName,omitempty"`
-	UserID            string `json:"userId,omitempty"`
-	UserType          string `json:"userType,omitempty"`
-	ActorID           int    `json:"actorId,omitempty"` //long
-	ActorType         string `json:"actorType,omitempty"`
-	ObjectName        string `json:"objectName,omitempty"`
-	ObjectID          string `json:"objectId,omitempty"`
-	ObjectType        string `json:"objectType,omitempty"`
-	AdditionalComment string `json:"additionalComment,omitempty"`
-	Time              string `json:"time,omitempty"`
-	EventText         string `json:"eventText,omitempty"`
-	Device            string `json:"device,omitempty"`
-	BrowserDetails    string `json:"browserDetails,omitempty"`
-	IPAddress         string `json:"ipAddress,omitempty"`
+	UserName          string     `json:"userName,omitempty"`
+	UserID            string     `json:"userId,omitempty"`
+	UserType          string     `json:"userType,omitempty"`
+	ActorID           int        `json:"actorId,omitempty"` //long
+	ActorType         string     `json:"actorType,omitempty"`
+	ObjectName        string     `json:"objectName,omitempty"`
+	ObjectID          string     `json:"objectId,omitempty"`
+	ObjectType        string     `json:"objectType,omitempty"`
+	AdditionalComment string     `json:"additionalComment,omitempty"`
+	Time              *Timestamp `json:"time,omitempty"`
+	EventText         string     `json:"eventText,omitempty"`
+	Device            string     `json:"device,omitempty"`
+	BrowserDetails    string     `json:"browserDetails,omitempty"`
+	IPAddress         string     `json:"ipAddress,omitempty"`
 }
 
 // AuditQueryParams contains all the query params that can be set for Domo Activity Log Queries.
@@ -0,0 +1,356 @@
+package domo
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Files written to each snapshot directory.
+const (
+	snapshotDatasetFile  = "dataset.json"
+	snapshotDataFile     = "data.csv.gz"
+	snapshotPoliciesFile = "policies.json"
+	backupManifestFile   = "manifest.json"
+)
+
+// BackupStore keeps local snapshots of datasets, to restore data lost to a bad REPLACE upload
+// since Domo doesn't version datasets. Each snapshot is a directory under Dir/<dataset id>/
+// holding the dataset's metadata and schema, its data as gzipped CSV and its PDP policies.
+// Dir/manifest.json lists every snapshot.
+type BackupStore struct {
+	Client *Client
+	// Dir the snapshots are kept in. It's created if it doesn't exist.
+	Dir string
+	// PartRows is the number of rows uploaded per data part by Restore. Defaults to DefaultPartRows.
+	PartRows int
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewBackupStore returns a BackupStore keeping its snapshots in dir.
+func NewBackupStore(client *Client, dir string) *BackupStore {
+	return &BackupStore{Client: client, Dir: dir}
+}
+
+// Snapshot describes a dataset snapshot in a BackupStore's manifest.
+type Snapshot struct {
+	DatasetID string    `json:"datasetId"`
+	Name      string    `json:"name"`
+	TakenAt   time.Time `json:"takenAt"`
+	Rows      int       `json:"rows"`
+	Columns   int       `json:"columns"`
+	Policies  int       `json:"policies"`
+	// Path of the snapshot directory relative to the store's Dir.
+	Path string `json:"path"`
+}
+
+// BackupManifest lists the snapshots in a BackupStore, oldest first.
+type BackupManifest struct {
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// Snapshot the dataset into a new snapshot directory and add it to the manifest.
+func (b *BackupStore) Snapshot(ctx context.Context, id string) (*Snapshot, error) {
+	ds, _, err := b.Client.Datasets.Info(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	policies, _, err := b.Client.Datasets.ListPolicies(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now
+	if b.now != nil {
+		now = b.now
+	}
+	takenAt := now().UTC()
+	snapshot := &Snapshot{
+		DatasetID: id,
+		Name:      ds.Name,
+		TakenAt:   takenAt,
+		Columns:   len(ds.Schema.Columns),
+		Policies:  len(policies),
+		Path:      filepath.Join(id, takenAt.Format("20060102T150405.000000000Z")),
+	}
+	dir := filepath.Join(b.Dir, snapshot.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := b.writeSnapshot(ctx, dir, ds, policies, snapshot); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("snapshotting dataset %s: %v", id, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	manifest, err := b.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	manifest.Snapshots = append(manifest.Snapshots, *snapshot)
+	return snapshot, b.writeManifest(manifest)
+}
+
+// SnapshotAll snapshots each of the datasets, stopping at the first error.
+func (b *BackupStore) SnapshotAll(ctx context.Context, ids []string) ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+	for _, id := range ids {
+		s, err := b.Snapshot(ctx, id)
+		if err != nil {
+			return snapshots, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, nil
+}
+
+func (b *BackupStore) writeSnapshot(ctx context.Context, dir string, ds *Dataset, policies []*Policy, snapshot *Snapshot) error {
+	if err := writeJSONFile(filepath.Join(dir, snapshotDatasetFile), ds); err != nil {
+		return err
+	}
+	if err := writeJSONFile(filepath.Join(dir, snapshotPoliciesFile), policies); err != nil {
+		return err
+	}
+
+	dataPath := filepath.Join(dir, snapshotDataFile)
+	f, err := os.Create(dataPath)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	_, err = b.Client.Datasets.ExportDataCSV(ctx, ds.ID, false, gz)
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	// count the rows from the file to check it reads back.
+	data, err := openSnapshotData(dataPath)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	r := csv.NewReader(data)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	for {
+		_, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		snapshot.Rows++
+	}
+}
+
+// Manifest returns the store's manifest.
+func (b *BackupStore) Manifest() (*BackupManifest, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.readManifest()
+}
+
+// Snapshots of the dataset, newest first.
+func (b *BackupStore) Snapshots(id string) ([]Snapshot, error) {
+	manifest, err := b.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []Snapshot
+	for _, s := range manifest.Snapshots {
+		if s.DatasetID == id {
+			snapshots = append(snapshots, s)
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].TakenAt.After(snapshots[j].TakenAt) })
+	return snapshots, nil
+}
+
+// Latest snapshot of the dataset, or nil if there isn't one.
+func (b *BackupStore) Latest(id string) (*Snapshot, error) {
+	snapshots, err := b.Snapshots(id)
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	return &snapshots[0], nil
+}
+
+// RestoreOptions configures BackupStore.Restore.
+type RestoreOptions struct {
+	// TargetID is an existing dataset whose data is replaced with the snapshot's. When empty a new
+	// dataset is created through a REPLACE stream.
+	TargetID string
+	// UpdateSchema changes the target's schema to the snapshot's if they differ. Otherwise a
+	// different schema fails the restore.
+	UpdateSchema bool
+	// RestorePolicies restores the snapshot's user PDP policies, and PDP if it was enabled.
+	RestorePolicies bool
+}
+
+// RestoreResult describes a restored snapshot.
+type RestoreResult struct {
+	// DatasetID the snapshot was restored to.
+	DatasetID string
+	// StreamID of the stream created for a new dataset, 0 when restoring to TargetID.
+	StreamID int
+	Rows     int
+	Policies *PolicyReconcileResult
+}
+
+// Restore the snapshot, either into a new dataset or by replacing the data of RestoreOptions.TargetID.
+func (b *BackupStore) Restore(ctx context.Context, snapshot Snapshot, opts RestoreOptions) (*RestoreResult, error) {
+	dir := filepath.Join(b.Dir, snapshot.Path)
+	var ds *Dataset
+	if err := readJSONFile(filepath.Join(dir, snapshotDatasetFile), &ds); err != nil {
+		return nil, err
+	}
+	var policies []*Policy
+	if err := readJSONFile(filepath.Join(dir, snapshotPoliciesFile), &policies); err != nil {
+		return nil, err
+	}
+	data, err := openSnapshotData(filepath.Join(dir, snapshotDataFile))
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	result := &RestoreResult{DatasetID: opts.TargetID, Rows: snapshot.Rows}
+	if opts.TargetID == "" {
+		stream, _, err := b.Client.Streams.CreateStream(ctx, StreamDatasetSchema{
+			DatasetSchema: &DatasetSchema{Name: ds.Name, Description: ds.Description, Schema: ds.Schema},
+			UpdateMethod:  UpdateMethodReplace,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if stream.Dataset == nil {
+			return nil, fmt.Errorf("restoring snapshot %s: stream %d has no dataset", snapshot.Path, stream.ID)
+		}
+		result.DatasetID, result.StreamID = stream.Dataset.ID, stream.ID
+		partRows := b.PartRows
+		if partRows <= 0 {
+			partRows = DefaultPartRows
+		}
+		if _, result.Rows, err = b.Client.Streams.ExecuteUploadReader(ctx, stream.ID, data, partRows); err != nil {
+			return result, err
+		}
+	} else {
+		target, _, err := b.Client.Datasets.Info(ctx, opts.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(target.Schema.Columns, ds.Schema.Columns) {
+			if !opts.UpdateSchema {
+				return nil, fmt.Errorf("dataset %s's schema differs from snapshot %s", opts.TargetID, snapshot.Path)
+			}
+			if _, _, err := b.Client.Datasets.UpdateSchema(ctx, opts.TargetID, ds.Schema); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := b.Client.Datasets.ImportDataReader(ctx, opts.TargetID, UpdateMethodReplace, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if !opts.RestorePolicies {
+		return result, nil
+	}
+	var desired []Policy
+	for _, p := range policies {
+		if p.PolicyType == PolicyTypeSystem {
+			continue
+		}
+		policy := *p
+		policy.ID = 0
+		desired = append(desired, policy)
+	}
+	if result.Policies, err = b.Client.Datasets.ReconcilePolicies(ctx, result.DatasetID, desired, false); err != nil {
+		return result, err
+	}
+	if ds.PDPEnabled {
+		if _, _, err := b.Client.Datasets.Update(ctx, result.DatasetID, DatasetUpdate{PDPEnabled: Ptr(true)}); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (b *BackupStore) readManifest() (*BackupManifest, error) {
+	manifest := &BackupManifest{}
+	err := readJSONFile(filepath.Join(b.Dir, backupManifestFile), manifest)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	return manifest, err
+}
+
+func (b *BackupStore) writeManifest(manifest *BackupManifest) error {
+	bytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(b.Dir, backupManifestFile), bytes)
+}
+
+// snapshotData is the decompressed data file of a snapshot.
+type snapshotData struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func openSnapshotData(path string) (*snapshotData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return &snapshotData{Reader: gz, f: f}, nil
+}
+
+func (d *snapshotData) Close() error {
+	d.Reader.Close()
+	return d.f.Close()
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+func readJSONFile(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("reading %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package domo
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func backupTestClient(t *testing.T, data *string, requests *[]string) (*Client, func()) {
+	router := testRouter{t: t, routes: []testRoute{
+		{"GET /v1/datasets/abc", testBody(`{"id": "abc", "name": "Sales", "pdpEnabled": true,
+			"schema": {"columns": [{"type": "STRING", "name": "region"}, {"type": "LONG", "name": "amount"}]}}`)},
+		{"GET /v1/datasets/other", testBody(`{"id": "other", "schema": {"columns": [{"type": "STRING", "name": "region"}]}}`)},
+		{"GET /v1/datasets/*/policies", testBody(`[{"id": 1, "type": "system", "name": "All Rows", "users": [27]},
+			{"id": 2, "type": "user", "name": "West", "users": [12], "filters": [{"column": "region", "operator": "EQUALS", "values": ["West"]}]}]`)},
+		{"GET /v1/datasets/abc/data", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(*data))
+		}},
+		{"PUT /v1/datasets/abc/data", func(w http.ResponseWriter, r *http.Request) {
+			if m := r.URL.Query().Get("updateMethod"); m != UpdateMethodReplace {
+				t.Errorf("Expected a REPLACE import, got %s", m)
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			*data = string(b)
+		}},
+		{"POST /v1/streams", func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, "../test_data/streams/create_new_stream.json")
+		}},
+		{"POST /v1/streams/*/executions", testBody(`{"id": 1, "currentState": "ACTIVE"}`)},
+		{"PUT /v1/streams/*/executions/*/part/*", func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			*data = string(b)
+		}},
+		{"PUT /v1/streams/*/executions/*/commit", testBody(`{"id": 1, "currentState": "SUCCESS"}`)},
+		{"POST /v1/datasets/*/policies", testBody(`{"id": 3, "type": "user", "name": "West"}`)},
+		{"PUT /v1/datasets/*", testBody(`{"id": "abc"}`)},
+	}}
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.Method+" "+r.URL.Path)
+		router.ServeHTTP(w, r)
+	})
+	return client, server.Close
+}
+
+func TestBackupStore_SnapshotAndRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "domo-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data := "West,1\n\"East\nCoast\",2\n"
+	var requests []string
+	client, closeServer := backupTestClient(t, &data, &requests)
+	defer closeServer()
+	ctx := context.Background()
+
+	store := NewBackupStore(client, dir)
+	taken := time.Date(2019, 3, 4, 15, 4, 5, 0, time.UTC)
+	store.now = func() time.Time { return taken }
+	first, err := store.Snapshot(ctx, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Rows != 2 || first.Columns != 2 || first.Policies != 2 || first.Name != "Sales" {
+		t.Errorf("Unexpected snapshot %+v", first)
+	}
+	for _, f := range []string{snapshotDatasetFile, snapshotDataFile, snapshotPoliciesFile} {
+		if _, err := os.Stat(filepath.Join(dir, first.Path, f)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// an accidental REPLACE wipes the data, a later snapshot captures that.
+	data = "North,3\n"
+	taken = taken.Add(time.Hour)
+	if _, err := store.Snapshot(ctx, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := store.Snapshots("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Rows != 1 || !snapshots[1].TakenAt.Equal(first.TakenAt) {
+		t.Fatalf("Expected 2 snapshots newest first, got %+v", snapshots)
+	}
+
+	requests = nil
+	result, err := store.Restore(ctx, snapshots[1], RestoreOptions{TargetID: "abc", RestorePolicies: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != "West,1\n\"East\nCoast\",2\n" || result.Rows != 2 || result.DatasetID != "abc" {
+		t.Errorf("Expected the first snapshot's data to be restored, got %q %+v", data, result)
+	}
+	if len(result.Policies.Created) != 0 || result.Policies.Unchanged != 1 {
+		t.Errorf("Expected the existing policy to be kept, got %+v", result.Policies)
+	}
+	if requests[len(requests)-1] != "PUT /v1/datasets/abc" {
+		t.Errorf("Expected PDP to be enabled, got requests %v", requests)
+	}
+
+	result, err = store.Restore(ctx, snapshots[1], RestoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StreamID != 42 || result.Rows != 2 || result.DatasetID != "0c1e0dbe-9f71-4625-9b50-b79e6e4266f2" {
+		t.Errorf("Unexpected restore to a new dataset %+v", result)
+	}
+
+	if _, err := store.Restore(ctx, snapshots[1], RestoreOptions{TargetID: "other"}); err == nil {
+		t.Error("Expected a schema mismatch to fail the restore")
+	}
+}
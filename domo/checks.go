@@ -0,0 +1,371 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCheckSamples is the number of offending rows a failed Check includes.
+const DefaultCheckSamples = 5
+
+// Check is a data quality assertion about a dataset, evaluated through Domo's query API or the
+// dataset's metadata. Implement it for custom checks.
+type Check interface {
+	// Name describes the check in a CheckResult.
+	Name() string
+	// Run the check against the dataset, including up to samples offending rows in a failed result.
+	// An error means the check couldn't be evaluated.
+	Run(ctx context.Context, client *Client, datasetID string, samples int) (CheckResult, error)
+}
+
+// CheckResult is the outcome of a single Check.
+type CheckResult struct {
+	Check   string `json:"check"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+	// Failing is the number of offending rows, when it's known.
+	Failing int `json:"failing,omitempty"`
+	// SampleColumns names the columns of the Samples.
+	SampleColumns []string   `json:"sampleColumns,omitempty"`
+	Samples       [][]string `json:"samples,omitempty"`
+	// Error is set when the check couldn't be evaluated. The check counts as failed.
+	Error string `json:"error,omitempty"`
+}
+
+// DatasetChecks binds checks to a dataset. It can be decoded from JSON with ParseDatasetChecks.
+type DatasetChecks struct {
+	DatasetID string
+	Checks    []Check
+	// Samples is the number of offending rows a failed check includes. Defaults to DefaultCheckSamples.
+	Samples int
+}
+
+// CheckReport holds the results of running a DatasetChecks.
+type CheckReport struct {
+	DatasetID string        `json:"datasetId"`
+	RunAt     time.Time     `json:"runAt"`
+	Passed    bool          `json:"passed"`
+	Results   []CheckResult `json:"results"`
+}
+
+// Failed returns the results of the checks that didn't pass.
+func (r *CheckReport) Failed() []CheckResult {
+	var failed []CheckResult
+	for _, result := range r.Results {
+		if !result.Passed {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Run every check against the dataset. A check that can't be evaluated fails with its Error set, the
+// other checks still run.
+func (d *DatasetChecks) Run(ctx context.Context, client *Client) *CheckReport {
+	samples := d.Samples
+	if samples <= 0 {
+		samples = DefaultCheckSamples
+	}
+	report := &CheckReport{DatasetID: d.DatasetID, RunAt: time.Now().UTC(), Passed: true}
+	for _, check := range d.Checks {
+		result, err := check.Run(ctx, client, d.DatasetID, samples)
+		result.Check = check.Name()
+		if err != nil {
+			result.Passed = false
+			result.Error = err.Error()
+		}
+		report.Passed = report.Passed && result.Passed
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// RowCountCheck passes when the dataset's row count is between Min and Max. A zero Max means no maximum.
+type RowCountCheck struct {
+	Min int
+	Max int
+}
+
+// Name of the check.
+func (c RowCountCheck) Name() string {
+	if c.Max == 0 {
+		return fmt.Sprintf("row_count(>= %d)", c.Min)
+	}
+	return fmt.Sprintf("row_count(%d..%d)", c.Min, c.Max)
+}
+
+// Run the check.
+func (c RowCountCheck) Run(ctx context.Context, client *Client, datasetID string, samples int) (CheckResult, error) {
+	count, err := queryCount(ctx, client, datasetID, "SELECT COUNT(*) FROM table")
+	if err != nil {
+		return CheckResult{}, err
+	}
+	result := CheckResult{Passed: count >= c.Min && (c.Max == 0 || count <= c.Max)}
+	result.Message = fmt.Sprintf("%d rows", count)
+	return result, nil
+}
+
+// NotNullCheck passes when none of the Columns have null values.
+type NotNullCheck struct {
+	Columns []string
+}
+
+// Name of the check.
+func (c NotNullCheck) Name() string {
+	return fmt.Sprintf("not_null(%s)", strings.Join(c.Columns, ", "))
+}
+
+// Run the check.
+func (c NotNullCheck) Run(ctx context.Context, client *Client, datasetID string, samples int) (CheckResult, error) {
+	if len(c.Columns) == 0 {
+		return CheckResult{}, fmt.Errorf("not null check has no columns")
+	}
+	conditions := make([]string, len(c.Columns))
+	for i, column := range c.Columns {
+		conditions[i] = quoteSQLColumn(column) + " IS NULL"
+	}
+	return whereCheck(ctx, client, datasetID, strings.Join(conditions, " OR "), samples, "rows with nulls")
+}
+
+// UniqueCheck passes when no two rows have the same values in Columns, i.e. the dataset's key columns.
+type UniqueCheck struct {
+	Columns []string
+}
+
+// Name of the check.
+func (c UniqueCheck) Name() string {
+	return fmt.Sprintf("unique(%s)", strings.Join(c.Columns, ", "))
+}
+
+// Run the check. Failing counts the duplicated keys found, up to 1000.
+func (c UniqueCheck) Run(ctx context.Context, client *Client, datasetID string, samples int) (CheckResult, error) {
+	if len(c.Columns) == 0 {
+		return CheckResult{}, fmt.Errorf("unique check has no columns")
+	}
+	columns := make([]string, len(c.Columns))
+	for i, column := range c.Columns {
+		columns[i] = quoteSQLColumn(column)
+	}
+	keys := strings.Join(columns, ", ")
+	sql := fmt.Sprintf("SELECT %s, COUNT(*) AS duplicates FROM table GROUP BY %s HAVING COUNT(*) > 1 LIMIT 1000", keys, keys)
+	rows, _, err := client.Datasets.Query(ctx, datasetID, sql)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	result := CheckResult{Passed: len(rows.Rows) == 0, Failing: len(rows.Rows)}
+	if !result.Passed {
+		result.Message = fmt.Sprintf("%d duplicated keys", len(rows.Rows))
+		result.SampleColumns = rows.Columns
+		result.Samples = limitRows(rows.StringRows(), samples)
+	}
+	return result, nil
+}
+
+// AcceptedValuesCheck passes when every non null value of Column is one of Values.
+type AcceptedValuesCheck struct {
+	Column string
+	Values []string
+}
+
+// Name of the check.
+func (c AcceptedValuesCheck) Name() string {
+	return fmt.Sprintf("accepted_values(%s)", c.Column)
+}
+
+// Run the check.
+func (c AcceptedValuesCheck) Run(ctx context.Context, client *Client, datasetID string, samples int) (CheckResult, error) {
+	if len(c.Values) == 0 {
+		return CheckResult{}, fmt.Errorf("accepted values check on %q has no values", c.Column)
+	}
+	values := make([]string, len(c.Values))
+	for i, v := range c.Values {
+		values[i] = quoteSQLString(v)
+	}
+	q := quoteSQLColumn(c.Column)
+	where := fmt.Sprintf("%s IS NOT NULL AND %s NOT IN (%s)", q, q, strings.Join(values, ", "))
+	return whereCheck(ctx, client, datasetID, where, samples, "rows with other values")
+}
+
+// FreshnessCheck passes when the dataset's data was updated within MaxAge, going by its DataCurrentAt,
+// or UpdatedAt if that's not set.
+type FreshnessCheck struct {
+	MaxAge time.Duration
+
+	now func() time.Time
+}
+
+// Name of the check.
+func (c FreshnessCheck) Name() string {
+	return fmt.Sprintf("freshness(%s)", c.MaxAge)
+}
+
+// Run the check.
+func (c FreshnessCheck) Run(ctx context.Context, client *Client, datasetID string, samples int) (CheckResult, error) {
+	ds, _, err := client.Datasets.Info(ctx, datasetID)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	updatedAt, ok := firstTimestamp(ds.DataCurrentAt, ds.UpdatedAt)
+	if !ok {
+		return CheckResult{}, fmt.Errorf("dataset %s has no update time", datasetID)
+	}
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	age := now().Sub(updatedAt)
+	return CheckResult{
+		Passed:  age <= c.MaxAge,
+		Message: fmt.Sprintf("updated %s ago at %s", age.Round(time.Second), updatedAt.Format(time.RFC3339)),
+	}, nil
+}
+
+// SQLCheck passes when its query returns no rows, i.e. "SELECT * FROM table WHERE amount < 0".
+type SQLCheck struct {
+	// Description names the check, defaults to the query.
+	Description string
+	SQL         string
+}
+
+// Name of the check.
+func (c SQLCheck) Name() string {
+	if c.Description != "" {
+		return c.Description
+	}
+	return fmt.Sprintf("sql(%s)", c.SQL)
+}
+
+// Run the check.
+func (c SQLCheck) Run(ctx context.Context, client *Client, datasetID string, samples int) (CheckResult, error) {
+	rows, _, err := client.Datasets.Query(ctx, datasetID, c.SQL)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	result := CheckResult{Passed: len(rows.Rows) == 0, Failing: len(rows.Rows)}
+	if !result.Passed {
+		result.Message = fmt.Sprintf("query returned %d rows", len(rows.Rows))
+		result.SampleColumns = rows.Columns
+		result.Samples = limitRows(rows.StringRows(), samples)
+	}
+	return result, nil
+}
+
+// whereCheck fails when any rows match where, counting them and sampling a few.
+func whereCheck(ctx context.Context, client *Client, datasetID, where string, samples int, description string) (CheckResult, error) {
+	count, err := queryCount(ctx, client, datasetID, "SELECT COUNT(*) FROM table WHERE "+where)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	result := CheckResult{Passed: count == 0, Failing: count}
+	if result.Passed {
+		return result, nil
+	}
+	result.Message = fmt.Sprintf("%d %s", count, description)
+	rows, _, err := client.Datasets.Query(ctx, datasetID, fmt.Sprintf("SELECT * FROM table WHERE %s LIMIT %d", where, samples))
+	if err != nil {
+		return result, err
+	}
+	result.SampleColumns = rows.Columns
+	result.Samples = rows.StringRows()
+	return result, nil
+}
+
+func queryCount(ctx context.Context, client *Client, datasetID, sql string) (int, error) {
+	rows, _, err := client.Datasets.Query(ctx, datasetID, sql)
+	if err != nil {
+		return 0, err
+	}
+	values := rows.StringRows()
+	if len(values) != 1 || len(values[0]) != 1 {
+		return 0, fmt.Errorf("expected a single count from %q", sql)
+	}
+	count, err := strconv.ParseFloat(values[0][0], 64)
+	return int(count), err
+}
+
+func limitRows(rows [][]string, n int) [][]string {
+	if len(rows) > n {
+		return rows[:n]
+	}
+	return rows
+}
+
+func quoteSQLString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// checkSpec is the JSON form of a Check.
+type checkSpec struct {
+	Type        string   `json:"type"`
+	Min         int      `json:"min,omitempty"`
+	Max         int      `json:"max,omitempty"`
+	Columns     []string `json:"columns,omitempty"`
+	Column      string   `json:"column,omitempty"`
+	Values      []string `json:"values,omitempty"`
+	MaxAge      string   `json:"maxAge,omitempty"`
+	Description string   `json:"description,omitempty"`
+	SQL         string   `json:"sql,omitempty"`
+}
+
+// ParseDatasetChecks decodes checks declared in JSON, a list of datasets with their checks:
+//
+//	[{"datasetId": "abc", "samples": 5, "checks": [
+//	    {"type": "row_count", "min": 1},
+//	    {"type": "not_null", "columns": ["id"]},
+//	    {"type": "unique", "columns": ["id"]},
+//	    {"type": "accepted_values", "column": "status", "values": ["open", "closed"]},
+//	    {"type": "freshness", "maxAge": "24h"},
+//	    {"type": "sql", "description": "no negative amounts", "sql": "SELECT * FROM table WHERE amount < 0"}
+//	]}]
+func ParseDatasetChecks(data []byte) ([]*DatasetChecks, error) {
+	var specs []struct {
+		DatasetID string      `json:"datasetId"`
+		Samples   int         `json:"samples"`
+		Checks    []checkSpec `json:"checks"`
+	}
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, err
+	}
+	var all []*DatasetChecks
+	for _, s := range specs {
+		if s.DatasetID == "" {
+			return nil, fmt.Errorf("checks are missing a datasetId")
+		}
+		d := &DatasetChecks{DatasetID: s.DatasetID, Samples: s.Samples}
+		for i, spec := range s.Checks {
+			check, err := spec.check()
+			if err != nil {
+				return nil, fmt.Errorf("dataset %s check %d: %v", s.DatasetID, i, err)
+			}
+			d.Checks = append(d.Checks, check)
+		}
+		all = append(all, d)
+	}
+	return all, nil
+}
+
+func (s checkSpec) check() (Check, error) {
+	switch s.Type {
+	case "row_count":
+		return RowCountCheck{Min: s.Min, Max: s.Max}, nil
+	case "not_null":
+		return NotNullCheck{Columns: s.Columns}, nil
+	case "unique":
+		return UniqueCheck{Columns: s.Columns}, nil
+	case "accepted_values":
+		return AcceptedValuesCheck{Column: s.Column, Values: s.Values}, nil
+	case "freshness":
+		maxAge, err := time.ParseDuration(s.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+		return FreshnessCheck{MaxAge: maxAge}, nil
+	case "sql":
+		return SQLCheck{Description: s.Description, SQL: s.SQL}, nil
+	}
+	return nil, fmt.Errorf("unknown check type %q", s.Type)
+}
@@ -0,0 +1,151 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDatasetChecks_Run(t *testing.T) {
+	var queries []string
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/datasets/abc":
+			json.NewEncoder(w).Encode(Dataset{ID: "abc", DataCurrentAt: mustTimestamp("2019-06-01T10:00:00Z"), UpdatedAt: mustTimestamp("2019-06-03T10:00:00Z")})
+		case "/v1/datasets/query/execute/abc":
+			var body struct {
+				SQL string `json:"sql"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			queries = append(queries, body.SQL)
+			switch {
+			case body.SQL == "SELECT COUNT(*) FROM table":
+				w.Write([]byte(`{"columns": ["COUNT(*)"], "rows": [[120]]}`))
+			case strings.HasPrefix(body.SQL, "SELECT COUNT(*) FROM table WHERE `id` IS NULL"):
+				w.Write([]byte(`{"columns": ["COUNT(*)"], "rows": [[0]]}`))
+			case strings.HasPrefix(body.SQL, "SELECT COUNT(*) FROM table WHERE `status`"):
+				w.Write([]byte(`{"columns": ["COUNT(*)"], "rows": [[2]]}`))
+			case strings.HasPrefix(body.SQL, "SELECT * FROM table WHERE `status`"):
+				w.Write([]byte(`{"columns": ["id", "status"], "rows": [[4, "lost"], [9, "won't"]]}`))
+			case strings.HasPrefix(body.SQL, "SELECT `id`, COUNT(*)"):
+				w.Write([]byte(`{"columns": ["id", "duplicates"], "rows": [[3, 2], [7, 2], [8, 3]]}`))
+			case strings.HasPrefix(body.SQL, "SELECT * FROM table WHERE amount < 0"):
+				w.Write([]byte(`{"columns": ["id", "amount"], "rows": []}`))
+			default:
+				t.Errorf("Unexpected query %q", body.SQL)
+			}
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer server.Close()
+
+	now := func() time.Time { return time.Date(2019, 6, 2, 10, 0, 0, 0, time.UTC) }
+	checks := &DatasetChecks{DatasetID: "abc", Samples: 2, Checks: []Check{
+		RowCountCheck{Min: 100, Max: 1000},
+		NotNullCheck{Columns: []string{"id"}},
+		UniqueCheck{Columns: []string{"id"}},
+		AcceptedValuesCheck{Column: "status", Values: []string{"open", "won't"}},
+		FreshnessCheck{MaxAge: 12 * time.Hour, now: now},
+		SQLCheck{Description: "no negative amounts", SQL: "SELECT * FROM table WHERE amount < 0"},
+	}}
+	report := checks.Run(context.Background(), client)
+
+	if report.Passed || report.DatasetID != "abc" || len(report.Results) != 6 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	passed := map[string]bool{}
+	for _, result := range report.Results {
+		passed[result.Check] = result.Passed
+		if result.Error != "" {
+			t.Errorf("Unexpected error in %+v", result)
+		}
+	}
+	expected := map[string]bool{
+		"row_count(100..1000)":    true,
+		"not_null(id)":            true,
+		"unique(id)":              false,
+		"accepted_values(status)": false,
+		"freshness(12h0m0s)":      false,
+		"no negative amounts":     true,
+	}
+	if !reflect.DeepEqual(passed, expected) {
+		t.Errorf("Expected %v, got %v", expected, passed)
+	}
+	if failed := report.Failed(); len(failed) != 3 {
+		t.Errorf("Expected 3 failed checks, got %+v", failed)
+	}
+
+	unique := report.Results[2]
+	if unique.Failing != 3 || len(unique.Samples) != 2 || unique.Samples[0][0] != "3" {
+		t.Errorf("Unexpected unique result %+v", unique)
+	}
+	accepted := report.Results[3]
+	if accepted.Failing != 2 || !reflect.DeepEqual(accepted.Samples, [][]string{{"4", "lost"}, {"9", "won't"}}) {
+		t.Errorf("Unexpected accepted values result %+v", accepted)
+	}
+	if !strings.Contains(strings.Join(queries, "\n"), "`status` NOT IN ('open', 'won''t')") {
+		t.Errorf("Accepted values weren't quoted in %q", queries)
+	}
+	if fresh := report.Results[4]; !strings.HasPrefix(fresh.Message, "updated 24h0m0s ago") {
+		t.Errorf("Unexpected freshness result %+v", fresh)
+	}
+}
+
+func TestDatasetChecks_RunError(t *testing.T) {
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"status": 400, "message": "bad query"}}`, http.StatusBadRequest)
+	})
+	defer server.Close()
+
+	checks := &DatasetChecks{DatasetID: "abc", Checks: []Check{
+		RowCountCheck{Min: 1},
+		NotNullCheck{},
+	}}
+	report := checks.Run(context.Background(), client)
+	if report.Passed || len(report.Results) != 2 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	for _, result := range report.Results {
+		if result.Passed || result.Error == "" {
+			t.Errorf("Expected an error in %+v", result)
+		}
+	}
+}
+
+func TestParseDatasetChecks(t *testing.T) {
+	data := `[{"datasetId": "abc", "samples": 3, "checks": [
+		{"type": "row_count", "min": 1},
+		{"type": "not_null", "columns": ["id", "day"]},
+		{"type": "unique", "columns": ["id"]},
+		{"type": "accepted_values", "column": "status", "values": ["open", "closed"]},
+		{"type": "freshness", "maxAge": "24h"},
+		{"type": "sql", "description": "no negative amounts", "sql": "SELECT * FROM table WHERE amount < 0"}
+	]}]`
+	all, err := ParseDatasetChecks([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*DatasetChecks{{DatasetID: "abc", Samples: 3, Checks: []Check{
+		RowCountCheck{Min: 1},
+		NotNullCheck{Columns: []string{"id", "day"}},
+		UniqueCheck{Columns: []string{"id"}},
+		AcceptedValuesCheck{Column: "status", Values: []string{"open", "closed"}},
+		FreshnessCheck{MaxAge: 24 * time.Hour},
+		SQLCheck{Description: "no negative amounts", SQL: "SELECT * FROM table WHERE amount < 0"},
+	}}}
+	if !reflect.DeepEqual(all, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, all)
+	}
+
+	if _, err := ParseDatasetChecks([]byte(`[{"datasetId": "abc", "checks": [{"type": "nope"}]}]`)); err == nil {
+		t.Error("Expected an error for an unknown check type")
+	}
+	if _, err := ParseDatasetChecks([]byte(`[{"checks": []}]`)); err == nil {
+		t.Error("Expected an error for a missing dataset id")
+	}
+}
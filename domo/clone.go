@@ -0,0 +1,133 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// CloneOptions configures CloneDataset.
+type CloneOptions struct {
+	// Target is the client of the Domo instance the clone is created in. Defaults to the source client.
+	Target *Client
+	// Name of the clone. Defaults to the source dataset's name.
+	Name string
+	// Description of the clone. Defaults to the source dataset's description.
+	Description string
+	// PartRows is the number of rows uploaded per data part. Defaults to DefaultPartRows.
+	PartRows int
+	// CopyPolicies copies the source's user PDP policies and enables PDP on the clone if it's enabled
+	// on the source. The All Rows policy is left as Domo creates it.
+	CopyPolicies bool
+	// MapPolicy, if set, is called with each copied policy to rewrite it, i.e. to map user and group IDs
+	// to the target instance's. Returning false skips the policy.
+	MapPolicy func(p Policy) (Policy, bool)
+}
+
+// CloneResult describes a cloned dataset.
+type CloneResult struct {
+	// Dataset is the clone.
+	Dataset *Dataset
+	// StreamID of the stream created to load the clone.
+	StreamID int
+	// Rows copied.
+	Rows      int
+	Execution *StreamExecution
+	// Policies created on the clone, nil unless CopyPolicies is set.
+	Policies *PolicyReconcileResult
+}
+
+// CloneDataset copies the dataset with the given id to a new dataset, on the same Domo instance or on
+// another one through CloneOptions.Target. The data is streamed from the source's export into a
+// multi-part stream upload, so the dataset is never held in memory.
+//
+// The clone is created through a new REPLACE stream since Domo only takes multi-part uploads through
+// streams. The stream can be used to refresh the clone later.
+func CloneDataset(ctx context.Context, source *Client, id string, opts CloneOptions) (*CloneResult, error) {
+	target := opts.Target
+	if target == nil {
+		target = source
+	}
+	partRows := opts.PartRows
+	if partRows <= 0 {
+		partRows = DefaultPartRows
+	}
+
+	src, _, err := source.Datasets.Info(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var policies []*Policy
+	if opts.CopyPolicies {
+		// list the policies first so a failure doesn't leave a clone behind.
+		if policies, _, err = source.Datasets.ListPolicies(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	name := opts.Name
+	if name == "" {
+		name = src.Name
+	}
+	description := opts.Description
+	if description == "" {
+		description = src.Description
+	}
+
+	stream, _, err := target.Streams.CreateStream(ctx, StreamDatasetSchema{
+		DatasetSchema: &DatasetSchema{Name: name, Description: description, Schema: src.Schema},
+		UpdateMethod:  UpdateMethodReplace,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating clone of %s: %v", id, err)
+	}
+	if stream.Dataset == nil {
+		return nil, fmt.Errorf("creating clone of %s: stream %d has no dataset", id, stream.ID)
+	}
+	result := &CloneResult{Dataset: stream.Dataset, StreamID: stream.ID}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := source.Datasets.ExportDataCSV(ctx, id, false, pw)
+		pw.CloseWithError(err)
+	}()
+	execution, rows, err := target.Streams.ExecuteUploadReader(ctx, stream.ID, pr, partRows)
+	// unblock the export if the upload stopped early.
+	pr.Close()
+	result.Rows = rows
+	if err != nil {
+		return result, fmt.Errorf("copying data of %s to %s: %v", id, result.Dataset.ID, err)
+	}
+	result.Execution = execution
+
+	if !opts.CopyPolicies {
+		return result, nil
+	}
+	var desired []Policy
+	for _, p := range policies {
+		if p.PolicyType == PolicyTypeSystem {
+			continue
+		}
+		policy := *p
+		policy.ID = 0
+		if opts.MapPolicy != nil {
+			var ok bool
+			if policy, ok = opts.MapPolicy(policy); !ok {
+				continue
+			}
+		}
+		desired = append(desired, policy)
+	}
+	result.Policies, err = target.Datasets.ReconcilePolicies(ctx, result.Dataset.ID, desired, false)
+	if err != nil {
+		return result, fmt.Errorf("copying policies of %s to %s: %v", id, result.Dataset.ID, err)
+	}
+	if src.PDPEnabled {
+		// enabled after the policies exist so the clone's rows are never hidden from their users.
+		ds, _, err := target.Datasets.Update(ctx, result.Dataset.ID, DatasetUpdate{PDPEnabled: Ptr(true)})
+		if err != nil {
+			return result, fmt.Errorf("enabling PDP on %s: %v", result.Dataset.ID, err)
+		}
+		result.Dataset = ds
+	}
+	return result, nil
+}
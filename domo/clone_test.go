@@ -0,0 +1,120 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCloneDataset(t *testing.T) {
+	source, sourceServer := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/datasets/src":
+			w.Write([]byte(`{"id": "src", "name": "Sales", "description": "sandbox sales", "pdpEnabled": true,
+				"schema": {"columns": [{"type": "STRING", "name": "region"}, {"type": "LONG", "name": "amount"}]}}`))
+		case "/v1/datasets/src/data":
+			if r.URL.Query().Get("includeHeader") != "false" {
+				t.Errorf("Expected the export without a header, got %s", r.URL)
+			}
+			w.Write([]byte("West,1\n\"East\nCoast\",2\nNorth,3\n"))
+		case "/v1/datasets/src/policies":
+			w.Write([]byte(`[{"id": 1, "type": "system", "name": "All Rows", "users": [27]},
+				{"id": 2, "type": "user", "name": "West", "users": [12], "filters": [{"column": "region", "operator": "EQUALS", "values": ["West"]}]},
+				{"id": 3, "type": "user", "name": "Skipped", "users": [13]}]`))
+		default:
+			t.Errorf("Unexpected source request %s %s", r.Method, r.URL)
+		}
+	})
+	defer sourceServer.Close()
+
+	var requests, parts []string
+	var created StreamDatasetSchema
+	var createdPolicy Policy
+	target, targetServer := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/streams":
+			json.NewDecoder(r.Body).Decode(&created)
+			http.ServeFile(w, r, "../test_data/streams/create_new_stream.json")
+		case r.Method == "POST" && r.URL.Path == "/v1/streams/42/executions":
+			w.Write([]byte(`{"id": 1, "currentState": "ACTIVE"}`))
+		case r.Method == "PUT" && strings.Contains(r.URL.Path, "/part/"):
+			b, _ := ioutil.ReadAll(r.Body)
+			parts = append(parts, string(b))
+		case r.Method == "PUT" && strings.HasSuffix(r.URL.Path, "/commit"):
+			w.Write([]byte(`{"id": 1, "currentState": "SUCCESS"}`))
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/policies"):
+			w.Write([]byte(`[{"id": 9, "type": "system", "name": "All Rows", "users": [1]}]`))
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/policies"):
+			json.NewDecoder(r.Body).Decode(&createdPolicy)
+			w.Write([]byte(`{"id": 10, "type": "user", "name": "West"}`))
+		case r.Method == "PUT" && r.URL.Path == "/v1/datasets/0c1e0dbe-9f71-4625-9b50-b79e6e4266f2":
+			w.Write([]byte(`{"id": "0c1e0dbe-9f71-4625-9b50-b79e6e4266f2", "pdpEnabled": true}`))
+		default:
+			t.Errorf("Unexpected target request %s %s", r.Method, r.URL)
+		}
+	})
+	defer targetServer.Close()
+
+	result, err := CloneDataset(context.Background(), source, "src", CloneOptions{
+		Target:       target,
+		Name:         "Sales (prod)",
+		PartRows:     2,
+		CopyPolicies: true,
+		MapPolicy: func(p Policy) (Policy, bool) {
+			for i, id := range p.UserIDs {
+				p.UserIDs[i] = id + 100
+			}
+			return p, p.Name != "Skipped"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := created.DatasetSchema
+	if ds.Name != "Sales (prod)" || ds.Description != "sandbox sales" || len(ds.Schema.Columns) != 2 || created.UpdateMethod != UpdateMethodReplace {
+		t.Errorf("Unexpected clone stream %+v %+v", created, ds)
+	}
+	if len(parts) != 2 || parts[0] != "West,1\n\"East\nCoast\",2\n" || parts[1] != "North,3\n" {
+		t.Errorf("Unexpected parts %q", parts)
+	}
+	if result.Rows != 3 || result.StreamID != 42 || result.Execution.CurrentState != ExecutionStateSuccess {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if createdPolicy.ID != 0 || createdPolicy.Name != "West" || createdPolicy.UserIDs[0] != 112 {
+		t.Errorf("Unexpected created policy %+v", createdPolicy)
+	}
+	if len(result.Policies.Created) != 1 || len(result.Policies.Deleted) != 0 {
+		t.Errorf("Expected only the West policy to be created, got %+v", result.Policies)
+	}
+	if !result.Dataset.PDPEnabled || requests[len(requests)-1] != "PUT /v1/datasets/0c1e0dbe-9f71-4625-9b50-b79e6e4266f2" {
+		t.Errorf("Expected PDP to be enabled last, got requests %v", requests)
+	}
+}
+
+func TestCloneDataset_ExportFails(t *testing.T) {
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/datasets/src":
+			w.Write([]byte(`{"id": "src", "name": "Sales"}`))
+		case r.URL.Path == "/v1/datasets/src/data":
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.URL.Path == "/v1/streams":
+			http.ServeFile(w, r, "../test_data/streams/create_new_stream.json")
+		case strings.HasSuffix(r.URL.Path, "/executions"):
+			w.Write([]byte(`{"id": 1, "currentState": "ACTIVE"}`))
+		case strings.HasSuffix(r.URL.Path, "/abort"):
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer server.Close()
+
+	if _, err := CloneDataset(context.Background(), client, "src", CloneOptions{}); err == nil {
+		t.Error("Expected the failed export to fail the clone")
+	}
+}
@@ -0,0 +1,384 @@
+package domo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts used to write DATE and DATETIME columns in uploaded CSVs.
+const (
+	csvDateLayout     = "2006-01-02"
+	csvDatetimeLayout = "2006-01-02T15:04:05Z"
+)
+
+// MarshalCSV serializes a slice or array of structs (or struct pointers) to CSV. The
+// columns are in the same order as the Schema GenerateDataSetSchema creates for the
+// element type. Domo's upload endpoints expect no header row.
+func MarshalCSV(data interface{}, includeHeader bool) (string, error) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("Expected data to be a Slice or Array but got type %s", v.Kind())
+	}
+	elemType := v.Type().Elem()
+	if elemType.Kind() == reflect.Interface {
+		// i.e. []interface{}, use the type of the first row for the columns.
+		if v.Len() == 0 || v.Index(0).IsNil() {
+			return "", nil
+		}
+		elemType = v.Index(0).Elem().Type()
+	}
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	si, err := getStructInfo(structType)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	if includeHeader {
+		header := make([]string, len(si.Fields))
+		for i, f := range si.Fields {
+			header[i] = f.getFirstKey()
+		}
+		if err := w.Write(header); err != nil {
+			return "", err
+		}
+	}
+	record := make([]string, len(si.Fields))
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		for row.Kind() == reflect.Ptr || row.Kind() == reflect.Interface {
+			row = row.Elem()
+		}
+		if !row.IsValid() {
+			return "", fmt.Errorf("row %d is nil", i)
+		}
+		if row.Type() != structType {
+			return "", fmt.Errorf("row %d is type %s, expected %s", i, row.Type(), structType)
+		}
+		for j, f := range si.Fields {
+			cell, err := f.formatCell(row)
+			if err != nil {
+				return "", fmt.Errorf("row %d column %s: %v", i, f.getFirstKey(), err)
+			}
+			record[j] = cell
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// fieldValue follows the field's IndexChain from the struct value v. ok is false when
+// a nil pointer is hit along the way.
+func (f fieldInfo) fieldValue(v reflect.Value) (fv reflect.Value, ok bool) {
+	for _, idx := range f.IndexChain {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	if f.elemIndex >= 0 {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		if f.elemIndex >= v.Len() {
+			return v, false
+		}
+		v = v.Index(f.elemIndex)
+	}
+	return v, true
+}
+
+// formatCell returns the CSV cell for the field of the struct value v.
+func (f fieldInfo) formatCell(v reflect.Value) (string, error) {
+	fv, ok := f.fieldValue(v)
+	if !ok {
+		return "", nil
+	}
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return "", nil
+		}
+		fv = fv.Elem()
+	}
+	if f.omitEmpty && fv.IsZero() {
+		return "", nil
+	}
+	if f.encodeJSON {
+		if (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Map) && fv.IsNil() {
+			return "", nil
+		}
+		b, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return formatValue(fv, f.DomoColumnType), nil
+}
+
+// formatValue formats a non JSON encoded value for a column of the given Domo type.
+func formatValue(v reflect.Value, columnType string) string {
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		if columnType == ColumnTypeDate {
+			return t.Format(csvDateLayout)
+		}
+		return t.UTC().Format(csvDatetimeLayout)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// UnmarshalCSV decodes CSV data with a header row into the slice of structs (or struct
+// pointers) pointed to by out. Header columns are matched to struct fields by the same
+// names GenerateDataSetSchema uses, columns without a matching field are ignored.
+func UnmarshalCSV(data string, out interface{}) error {
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dec, err := newRowDecoder(out, header)
+	if err != nil {
+		return err
+	}
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := dec.append(record); err != nil {
+			return fmt.Errorf("line %d: %v", line+1, err)
+		}
+	}
+	return nil
+}
+
+// rowDecoder appends decoded records to a slice of structs.
+type rowDecoder struct {
+	slice   reflect.Value
+	elem    reflect.Type
+	isPtr   bool
+	columns []*fieldInfo
+}
+
+func newRowDecoder(out interface{}, header []string) (*rowDecoder, error) {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return nil, fmt.Errorf("Expected out to be a pointer to a Slice but got type %s", v.Type())
+	}
+	dec := &rowDecoder{slice: v.Elem(), elem: v.Elem().Type().Elem()}
+	if dec.elem.Kind() == reflect.Ptr {
+		dec.isPtr = true
+		dec.elem = dec.elem.Elem()
+	}
+	si, err := getStructInfo(dec.elem)
+	if err != nil {
+		return nil, err
+	}
+	dec.columns = make([]*fieldInfo, len(header))
+	for i, h := range header {
+		name := normalizeName(h)
+		for j := range si.Fields {
+			if si.Fields[j].matchesKey(name) {
+				dec.columns[i] = &si.Fields[j]
+				break
+			}
+		}
+	}
+	return dec, nil
+}
+
+func (d *rowDecoder) append(record []string) error {
+	row := reflect.New(d.elem)
+	for i, cell := range record {
+		if i >= len(d.columns) || d.columns[i] == nil {
+			continue
+		}
+		if err := d.columns[i].setCell(row.Elem(), cell); err != nil {
+			return fmt.Errorf("column %s: %v", d.columns[i].getFirstKey(), err)
+		}
+	}
+	if d.isPtr {
+		d.slice.Set(reflect.Append(d.slice, row))
+	} else {
+		d.slice.Set(reflect.Append(d.slice, row.Elem()))
+	}
+	return nil
+}
+
+// setCell parses cell into the field of the struct value v, allocating nil pointers
+// along the way. Empty cells leave the field's zero value.
+func (f fieldInfo) setCell(v reflect.Value, cell string) error {
+	if cell == "" {
+		return nil
+	}
+	for _, idx := range f.IndexChain {
+		v = allocIndirect(v).Field(idx)
+	}
+	if f.elemIndex >= 0 {
+		v = allocIndirect(v)
+		if v.Kind() == reflect.Slice {
+			for v.Len() <= f.elemIndex {
+				v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+			}
+		}
+		v = v.Index(f.elemIndex)
+	}
+	if f.encodeJSON {
+		return json.Unmarshal([]byte(cell), v.Addr().Interface())
+	}
+	return parseValue(allocIndirect(v), cell)
+}
+
+// allocIndirect follows pointers from v, allocating any that are nil.
+func allocIndirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// parseValue sets v from the string representation of a non JSON encoded cell.
+func parseValue(v reflect.Value, s string) error {
+	if v.Type() == timeType {
+		t, err := parseCSVTime(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// csvTimeLayouts are the layouts Domo uses for DATE and DATETIME values in exports and queries.
+var csvTimeLayouts = []string{time.RFC3339Nano, csvDatetimeLayout, "2006-01-02 15:04:05", "2006-01-02T15:04:05", csvDateLayout}
+
+func parseCSVTime(s string) (time.Time, error) {
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date/time %q", s)
+}
+
+// splitCSV reads CSV records from r and passes them to part partRows records at a time, re-encoded as CSV.
+func splitCSV(r io.Reader, partRows int, part func(csvPart string, rows int) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	rows := 0
+	flush := func() error {
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+		if rows == 0 {
+			return nil
+		}
+		err := part(buf.String(), rows)
+		buf.Reset()
+		rows = 0
+		return err
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+		rows++
+		if rows == partRows {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
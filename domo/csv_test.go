@@ -0,0 +1,93 @@
+package domo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarshalCSV(t *testing.T) {
+	type row struct {
+		Name  string       `domo:"name"`
+		Count int          `domo:"count"`
+		Ratio float64      `domo:"ratio,omitempty"`
+		Day   time.Time    `domo:"day,DATE"`
+		Home  *DomoAddress `domo:"home,prefix=home_"`
+		Tags  []string     `domo:"tags"`
+		Codes []int        `domo:"code,explode=2"`
+	}
+	day := time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)
+	rows := []row{
+		{Name: "a, b", Count: 1, Ratio: 0.5, Day: day, Home: &DomoAddress{Name: "h", Street: "1st"}, Tags: []string{"x", "y"}, Codes: []int{7}},
+		{Name: "c", Count: 2},
+	}
+	got, err := MarshalCSV(rows, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "name,count,ratio,day,home_Name,home_Street,tags,code_0,code_1\n" +
+		"\"a, b\",1,0.5,2019-03-04,h,1st,\"[\"\"x\"\",\"\"y\"\"]\",7,\n" +
+		"c,2,,,,,,,\n"
+	if got != expected {
+		t.Errorf("Unexpected CSV.\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+func TestMarshalCSV_NotASlice(t *testing.T) {
+	if _, err := MarshalCSV(DomoAddress{}, false); err == nil {
+		t.Error("Expected an error marshalling a struct that isn't in a slice")
+	}
+}
+
+func TestUnmarshalCSV(t *testing.T) {
+	type row struct {
+		Name  string       `domo:"name"`
+		Count *int         `domo:"count"`
+		Day   time.Time    `domo:"day,DATE"`
+		Home  *DomoAddress `domo:"home,prefix=home_"`
+		Tags  []string     `domo:"tags"`
+		Codes []int        `domo:"code,explode=2"`
+	}
+	data := "name,unknown,count,day,home_Street,tags,code_0,code_1\n" +
+		"\"a, b\",x,1,2019-03-04,1st,\"[\"\"x\"\",\"\"y\"\"]\",7,\n" +
+		"c,,,,,,,8\n"
+	var rows []row
+	if err := UnmarshalCSV(data, &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+	first := rows[0]
+	if first.Name != "a, b" || first.Count == nil || *first.Count != 1 {
+		t.Errorf("Unexpected name or count in %+v", first)
+	}
+	if !first.Day.Equal(time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected day %v", first.Day)
+	}
+	if first.Home == nil || first.Home.Street != "1st" {
+		t.Errorf("Expected home street 1st, got %+v", first.Home)
+	}
+	if len(first.Tags) != 2 || first.Tags[1] != "y" {
+		t.Errorf("Unexpected tags %v", first.Tags)
+	}
+	if len(first.Codes) != 1 || first.Codes[0] != 7 {
+		t.Errorf("Unexpected codes %v", first.Codes)
+	}
+	second := rows[1]
+	if second.Count != nil || second.Home != nil {
+		t.Errorf("Expected empty cells to leave nil pointers, got %+v", second)
+	}
+	if len(second.Codes) != 2 || second.Codes[1] != 8 {
+		t.Errorf("Unexpected codes %v", second.Codes)
+	}
+}
+
+func TestUnmarshalCSV_BadValue(t *testing.T) {
+	type row struct {
+		Count int `domo:"count"`
+	}
+	var rows []row
+	if err := UnmarshalCSV("count\nnope\n", &rows); err == nil {
+		t.Error("Expected an error parsing a LONG column")
+	}
+}
@@ -3,10 +3,13 @@ package domo
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -22,18 +25,18 @@ const (
 
 // Dataset contains basic data about a domo Dataset.
 type Dataset struct {
-	ID            string   `json:"id,omitempty"`
-	Name          string   `json:"name,omitempty"`
-	Description   string   `json:"description,omitempty"`
-	Columns       int      `json:"columns,omitempty"`
-	Rows          int      `json:"rows,omitempty"`
-	Schema        Schema   `json:"schema,omitempty"`
-	CreatedAt     string   `json:"createdAt,omitempty"`
-	UpdatedAt     string   `json:"updatedAt,omitempty"`
-	DataCurrentAt string   `json:"dataCurrentAt,omitempty"`
-	PDPEnabled    bool     `json:"pdpEnabled,omitempty"`
-	Owner         *Owner   `json:"owner,omitempty"`
-	Policies      []Policy `json:"policies,omitempty"`
+	ID            string     `json:"id,omitempty"`
+	Name          string     `json:"name,omitempty"`
+	Description   string     `json:"description,omitempty"`
+	Columns       int        `json:"columns,omitempty"`
+	Rows          int        `json:"rows,omitempty"`
+	Schema        Schema     `json:"schema,omitempty"`
+	CreatedAt     *Timestamp `json:"createdAt,omitempty"`
+	UpdatedAt     *Timestamp `json:"updatedAt,omitempty"`
+	DataCurrentAt *Timestamp `json:"dataCurrentAt,omitempty"`
+	PDPEnabled    bool       `json:"pdpEnabled,omitempty"`
+	Owner         *Owner     `json:"owner,omitempty"`
+	Policies      []Policy   `json:"policies,omitempty"`
 }
 
 // DatasetSchema contains basic data about dataset and schema
@@ -85,15 +88,42 @@ type Filter struct {
 // Domo API Docs: https://developer.domo.com/docs/dataset-api-reference/dataset
 type DatasetsService service
 
+// Dataset sort orders for DatasetListOptions.
+const (
+	DatasetSortName          = "name"
+	DatasetSortLastTouched   = "lastTouched"
+	DatasetSortLastUpdated   = "lastUpdated"
+	DatasetSortCardCount     = "cardCount"
+	DatasetSortCardViewCount = "cardViewCount"
+)
+
+// DatasetListOptions filters and sorts the datasets returned by DatasetsService.ListWithOptions.
+type DatasetListOptions struct {
+	// Limit should be between 1 and 50.
+	Limit  int
+	Offset int
+	// Sort is one of the DatasetSort fields, Domo's default is DatasetSortName.
+	Sort string
+	// Descending reverses the sort.
+	Descending bool
+	// NameLike only returns datasets whose name contains it.
+	NameLike string
+}
+
 // List the datasets. Limit should be between 1 and 50.
 func (s *DatasetsService) List(ctx context.Context, limit, offset int) ([]*Dataset, *http.Response, error) {
-	if limit < 1 {
-		return nil, nil, fmt.Errorf("limit must be above 0, but %d is not", limit)
+	return s.ListWithOptions(ctx, DatasetListOptions{Limit: limit, Offset: offset})
+}
+
+// ListWithOptions lists the datasets matching the options, sorted by the options' Sort.
+func (s *DatasetsService) ListWithOptions(ctx context.Context, opts DatasetListOptions) ([]*Dataset, *http.Response, error) {
+	if opts.Limit < 1 {
+		return nil, nil, fmt.Errorf("limit must be above 0, but %d is not", opts.Limit)
 	}
-	if limit > 50 {
-		return nil, nil, fmt.Errorf("limit must be 50 or below, but %d is not", limit)
+	if opts.Limit > 50 {
+		return nil, nil, fmt.Errorf("limit must be 50 or below, but %d is not", opts.Limit)
 	}
-	u := fmt.Sprintf("v1/datasets?limit=%d&offset=%d", limit, offset)
+	u := fmt.Sprintf("v1/datasets?%s", generateDatasetListURLParams(opts))
 	req, err := s.client.NewRequest("GET", u, nil)
 	if err != nil {
 		return nil, nil, err
@@ -109,6 +139,45 @@ func (s *DatasetsService) List(ctx context.Context, limit, offset int) ([]*Datas
 	return datasets, resp, nil
 }
 
+// creates the query param(s) string for the dataset list. It'll order the params alphabetically.
+func generateDatasetListURLParams(opts DatasetListOptions) string {
+	q := url.Values{}
+	q.Add("limit", strconv.Itoa(opts.Limit))
+	q.Add("offset", strconv.Itoa(opts.Offset))
+	if opts.NameLike != "" {
+		q.Add("nameLike", opts.NameLike)
+	}
+	if opts.Sort != "" {
+		sort := opts.Sort
+		if opts.Descending {
+			sort = "-" + sort
+		}
+		q.Add("sort", sort)
+	}
+	return q.Encode()
+}
+
+// FindByName returns the datasets named name exactly. Domo filters the datasets by name so only
+// the matching pages are downloaded.
+func (s *DatasetsService) FindByName(ctx context.Context, name string) ([]*Dataset, error) {
+	const limit = 50
+	var found []*Dataset
+	for offset := 0; ; offset += limit {
+		datasets, _, err := s.ListWithOptions(ctx, DatasetListOptions{Limit: limit, Offset: offset, NameLike: name})
+		if err != nil {
+			return nil, err
+		}
+		for _, ds := range datasets {
+			if ds.Name == name {
+				found = append(found, ds)
+			}
+		}
+		if len(datasets) < limit {
+			return found, nil
+		}
+	}
+}
+
 // Info for the dataset for the given dataset id.
 func (s *DatasetsService) Info(ctx context.Context, id string) (*Dataset, *http.Response, error) {
 	u := fmt.Sprintf("v1/datasets/%s", id)
@@ -145,13 +214,29 @@ func (s *DatasetsService) Create(ctx context.Context, ds Dataset) (*Dataset, *ht
 	return d, resp, nil
 }
 
-// UpdateSchema updates the Dataset Schema for the Dataset ID provided.
-func (s *DatasetsService) UpdateSchema(ctx context.Context, id string, schema Schema) (*Dataset, *http.Response, error) {
+// DatasetUpdate is a partial update of a Dataset's metadata. Only the fields that are set are
+// changed, so a field can be set to its zero value, i.e. PDPEnabled: Ptr(false).
+type DatasetUpdate struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+	PDPEnabled  *bool   `json:"pdpEnabled,omitempty"`
+	// Owner to transfer the dataset to. Only the owner ID is needed.
+	Owner *Owner `json:"owner,omitempty"`
+}
+
+// Ptr returns a pointer to v, for setting the optional fields of updates like DatasetUpdate.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Update the fields set in update for the Dataset ID provided in a single request.
+func (s *DatasetsService) Update(ctx context.Context, id string, update DatasetUpdate) (*Dataset, *http.Response, error) {
+	if update == (DatasetUpdate{}) {
+		return nil, nil, fmt.Errorf("dataset update for %s has no fields set", id)
+	}
 	u := fmt.Sprintf("v1/datasets/%s", id)
-	ds := struct {
-		Schema Schema `json:"schema"`
-	}{Schema: schema}
-	req, err := s.client.NewRequest("PUT", u, ds)
+	req, err := s.client.NewRequest("PUT", u, update)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -166,46 +251,19 @@ func (s *DatasetsService) UpdateSchema(ctx context.Context, id string, schema Sc
 	return d, resp, nil
 }
 
+// UpdateSchema updates the Dataset Schema for the Dataset ID provided.
+func (s *DatasetsService) UpdateSchema(ctx context.Context, id string, schema Schema) (*Dataset, *http.Response, error) {
+	return s.Update(ctx, id, DatasetUpdate{Schema: &schema})
+}
+
 // UpdateName updates the Dataset Name for the Dataset ID provided.
 func (s *DatasetsService) UpdateName(ctx context.Context, id, name string) (*Dataset, *http.Response, error) {
-	u := fmt.Sprintf("v1/datasets/%s", id)
-	ds := struct {
-		Name string `json:"name"`
-	}{Name: name}
-	req, err := s.client.NewRequest("PUT", u, ds)
-	if err != nil {
-		return nil, nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-
-	var d *Dataset
-	resp, err := s.client.Do(ctx, req, &d)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return d, resp, nil
+	return s.Update(ctx, id, DatasetUpdate{Name: &name})
 }
 
 // UpdateDescription updates the Dataset Description for the Dataset ID provided.
 func (s *DatasetsService) UpdateDescription(ctx context.Context, id, description string) (*Dataset, *http.Response, error) {
-	u := fmt.Sprintf("v1/datasets/%s", id)
-	ds := struct {
-		Description string `json:"description"`
-	}{Description: description}
-	req, err := s.client.NewRequest("PUT", u, ds)
-	if err != nil {
-		return nil, nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-
-	var d *Dataset
-	resp, err := s.client.Do(ctx, req, &d)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return d, resp, nil
+	return s.Update(ctx, id, DatasetUpdate{Description: &description})
 }
 
 // Delete a specified Domo Dataset by Dataset ID.
@@ -227,34 +285,53 @@ func (s *DatasetsService) Delete(ctx context.Context, id string) (*http.Response
 
 // UploadDataStr Uploads a string CSV to the given dataset. If the dataset is set to append it will append the CSV otherwise it will replace.
 func (s *DatasetsService) UploadDataStr(ctx context.Context, id string, dataCSV string) (*http.Response, error) {
-	u := fmt.Sprintf("v1/datasets/%s/data", id)
-	buf := new(bytes.Buffer)
-	buf.WriteString(dataCSV)
-	req, err := s.client.NewRequest("POST", u, buf)
-	if err != nil {
-		return nil, err
+	return s.importData(ctx, id, "", strings.NewReader(dataCSV), int64(len(dataCSV)))
+}
+
+// UploadDataReader streams CSV from r to the given dataset, like UploadDataStr without holding the CSV in memory.
+func (s *DatasetsService) UploadDataReader(ctx context.Context, id string, r io.Reader) (*http.Response, error) {
+	return s.importData(ctx, id, "", r, -1)
+}
+
+// ImportDataStr uploads a string CSV to the given dataset with the given update method, UpdateMethodAppend or
+// UpdateMethodReplace, regardless of how the dataset is set up.
+func (s *DatasetsService) ImportDataStr(ctx context.Context, id, updateMethod, dataCSV string) (*http.Response, error) {
+	if updateMethod == "" {
+		return nil, fmt.Errorf("updateMethod must be %s or %s, but %q is not", UpdateMethodAppend, UpdateMethodReplace, updateMethod)
 	}
-	req.Header.Set("Content-Type", "text/csv")
+	return s.importData(ctx, id, updateMethod, strings.NewReader(dataCSV), int64(len(dataCSV)))
+}
 
-	resp, err := s.client.Do(ctx, req, nil)
-	if err != nil {
-		return resp, err
+// ImportDataReader streams CSV from r to the given dataset with the given update method, like ImportDataStr
+// without holding the CSV in memory.
+func (s *DatasetsService) ImportDataReader(ctx context.Context, id, updateMethod string, r io.Reader) (*http.Response, error) {
+	if updateMethod == "" {
+		return nil, fmt.Errorf("updateMethod must be %s or %s, but %q is not", UpdateMethodAppend, UpdateMethodReplace, updateMethod)
 	}
-	return resp, nil
+	return s.importData(ctx, id, updateMethod, r, -1)
+}
+
+// importData uploads size bytes of CSV from r to the dataset, or until EOF when size is -1. An empty
+// updateMethod uses the dataset's own update method.
+func (s *DatasetsService) importData(ctx context.Context, id, updateMethod string, r io.Reader, size int64) (*http.Response, error) {
+	method, u := "POST", fmt.Sprintf("v1/datasets/%s/data", id)
+	if updateMethod != "" {
+		if updateMethod != UpdateMethodAppend && updateMethod != UpdateMethodReplace {
+			return nil, fmt.Errorf("updateMethod must be %s or %s, but %q is not", UpdateMethodAppend, UpdateMethodReplace, updateMethod)
+		}
+		method, u = "PUT", u+"?updateMethod="+updateMethod
+	}
+	return s.client.doUpload(ctx, method, u, r, size, 0, nil)
 }
 
 // UploadData serializes an array of structs to CSV and then uploads them to the Domo Dataset.
+// Use FindSchemaChanges beforehand to make sure the struct matches the dataset's schema.
 func (s *DatasetsService) UploadData(ctx context.Context, id string, data interface{}) (*http.Response, error) {
-	// k := reflect.TypeOf(data).Kind()
-	// if k != reflect.Slice && k != reflect.Array {
-	// 	return nil, fmt.Errorf("Expected data to be a Slice or Array but got type %s", k)
-	// }
-	// schema := GenerateDataSetSchema(reflect.TypeOf(data).Elem())
-	// _, _, err := s.UpdateSchema(ctx, id, schema)
-	// if err != nil {
-	// 	return nil, err
-	// }
-	return nil, fmt.Errorf("Not Implemented")
+	csv, err := MarshalCSV(data, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.UploadDataStr(ctx, id, csv)
 }
 
 // HasSchemaChanged checks if a structs generated Schema differs from the Schema of a domo dataset.
@@ -264,7 +341,10 @@ func (s *DatasetsService) HasSchemaChanged(ctx context.Context, datasetID string
 	if err != nil {
 		return true, err
 	}
-	currentSchema := GenerateDataSetSchema(rType)
+	currentSchema, err := GenerateDataSetSchema(rType)
+	if err != nil {
+		return true, err
+	}
 	if len(currentSchema.Columns) != len(ds.Schema.Columns) {
 		return true, nil
 	}
@@ -377,7 +457,10 @@ func (s *DatasetsService) FindSchemaChanges(ctx context.Context, id string, rTyp
 	if err != nil {
 		return err
 	}
-	currentSchema := GenerateDataSetSchema(rType)
+	currentSchema, err := GenerateDataSetSchema(rType)
+	if err != nil {
+		return err
+	}
 	var diffs SchemaDiffError
 	if len(currentSchema.Columns) != len(ds.Schema.Columns) {
 		// Column count is different. Check for differences by column name.
@@ -501,6 +584,27 @@ func (s *DatasetsService) DownloadDatasetCSV(ctx context.Context, id string, inc
 	return csv, resp, nil
 }
 
+// ExportDataCSV streams the dataset's data as CSV to w without holding it in memory.
+func (s *DatasetsService) ExportDataCSV(ctx context.Context, id string, includeHeader bool, w io.Writer) (*http.Response, error) {
+	u := fmt.Sprintf("v1/datasets/%s/data?includeHeader=%t", id, includeHeader)
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/csv")
+
+	resp, err := s.client.Do(ctx, req, nil)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
 // QueryData takes a sql query and uses it to return a json string of the query table results for the dataset.
 // see https://developer.domo.com/docs/dataset-api-reference/dataset#Query%20a%20DataSet for an example response.
 func (s *DatasetsService) QueryData(ctx context.Context, id, sqlQuery string) (string, *http.Response, error) {
@@ -527,3 +631,58 @@ func (s *DatasetsService) QueryData(ctx context.Context, id, sqlQuery string) (s
 	csv := buf.String()
 	return csv, resp, nil
 }
+
+// QueryResult is the table returned by a dataset SQL query.
+type QueryResult struct {
+	Datasource string          `json:"datasource"`
+	Columns    []string        `json:"columns"`
+	Metadata   []QueryMetadata `json:"metadata"`
+	Rows       [][]interface{} `json:"rows"`
+	NumRows    int             `json:"numRows"`
+	NumColumns int             `json:"numColumns"`
+	FromCache  bool            `json:"fromcache"`
+}
+
+// QueryMetadata describes a column of a QueryResult.
+type QueryMetadata struct {
+	ColumnType   string `json:"type"`
+	DataSourceID string `json:"dataSourceId"`
+	MaxLength    int    `json:"maxLength"`
+	MinLength    int    `json:"minLength"`
+	PeriodIndex  int    `json:"periodIndex"`
+}
+
+// Query runs a sql query against the dataset like QueryData, but decodes the results. Numbers in Rows are
+// json.Number values so LONG columns don't lose precision.
+func (s *DatasetsService) Query(ctx context.Context, id, sqlQuery string) (*QueryResult, *http.Response, error) {
+	data, resp, err := s.QueryData(ctx, id, sqlQuery)
+	if err != nil {
+		return nil, resp, err
+	}
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var result *QueryResult
+	if err := dec.Decode(&result); err != nil {
+		return nil, resp, err
+	}
+	return result, resp, nil
+}
+
+// StringRows returns the query result rows with every value formatted the way a CSV export would have it.
+func (r *QueryResult) StringRows() [][]string {
+	rows := make([][]string, len(r.Rows))
+	for i, row := range r.Rows {
+		rows[i] = make([]string, len(row))
+		for j, v := range row {
+			switch val := v.(type) {
+			case nil:
+				rows[i][j] = ""
+			case string:
+				rows[i][j] = val
+			default:
+				rows[i][j] = fmt.Sprint(val)
+			}
+		}
+	}
+	return rows
+}
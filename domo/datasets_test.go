@@ -4,8 +4,11 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -43,7 +46,10 @@ func Test_checkForSchemaChangeByColumnNameMatching(t *testing.T) {
 		Name:       "obaz",
 	}}}
 
-	sample := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	sample, err := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	diffs := checkForSchemaChangeByColumnNameMatching(sample, domo)
 
@@ -79,7 +85,10 @@ func Test_checkForSchemaChangeByColumnNameMatching_addColumn(t *testing.T) {
 		Name:       "obar",
 	}}}
 
-	sample := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	sample, err := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	diffs := checkForSchemaChangeByColumnNameMatching(sample, domo)
 
@@ -122,7 +131,10 @@ func Test_checkForSchemaChangeByColumnNameMatching_addColumn_andChangeDataType(t
 		Name:       "obar",
 	}}}
 
-	sample := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	sample, err := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	diffs := checkForSchemaChangeByColumnNameMatching(sample, domo)
 
@@ -178,7 +190,10 @@ func Test_checkForSchemaChangeByColumnNameMatching_deleteColumn(t *testing.T) {
 		Name:       "ColumnInDomoToDelete",
 	}}}
 
-	sample := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	sample, err := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	diffs := checkForSchemaChangeByColumnNameMatching(sample, domo)
 
@@ -224,7 +239,10 @@ func Test_checkForSchemaChangeByColumnIndexComparision(t *testing.T) {
 		Name:       "obaz",
 	}}}
 
-	sample := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	sample, err := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	diffs := checkForSchemaChangeByColumnIndexComparision(sample, domo)
 
@@ -264,7 +282,10 @@ func Test_checkForSchemaChangeByColumnIndexComparision_Name_andType_Diff(t *test
 		Name:       "OBizzle",
 	}}}
 
-	sample := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	sample, err := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	diffs := checkForSchemaChangeByColumnIndexComparision(sample, domo)
 
@@ -379,3 +400,83 @@ func TestDatasetsService_DownloadDatasetCSV(t *testing.T) {
 	fmt.Printf("Dataset:\n%s\n", dataset)
 	// }
 }
+
+func Test_generateDatasetListURLParams(t *testing.T) {
+	params := DatasetListOptions{Limit: 50, Offset: 100, Sort: DatasetSortLastUpdated, Descending: true, NameLike: "sales data"}
+	expected := "limit=50&nameLike=sales+data&offset=100&sort=-lastUpdated" // It'll order params alphabetically
+	actual := generateDatasetListURLParams(params)
+	if actual != expected {
+		t.Errorf("Expected URL params: %s\nFound URL params:  %s", expected, actual)
+	}
+}
+
+func Test_generateDatasetListURLParams_no_filters_set(t *testing.T) {
+	params := DatasetListOptions{Limit: 5}
+	expected := "limit=5&offset=0"
+	actual := generateDatasetListURLParams(params)
+	if actual != expected {
+		t.Errorf("Expected URL params: %s\nFound URL params:  %s", expected, actual)
+	}
+}
+
+func TestDatasetsService_FindByName(t *testing.T) {
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("nameLike") != "Sales" {
+			t.Errorf("Expected the name to be filtered on, got %s", r.URL)
+		}
+		w.Write([]byte(`[{"id": "1", "name": "Sales"}, {"id": "2", "name": "Sales Forecast"}]`))
+	})
+	defer server.Close()
+
+	found, err := client.Datasets.FindByName(context.Background(), "Sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != "1" {
+		t.Errorf("Expected only the exact match, got %+v", found)
+	}
+	if _, _, err := client.Datasets.ListWithOptions(context.Background(), DatasetListOptions{Limit: 51}); err == nil {
+		t.Error("Expected an error for a limit above 50")
+	}
+}
+
+func TestDatasetsService_Update(t *testing.T) {
+	var body string
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/v1/datasets/abc" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		body = strings.TrimSpace(string(b))
+		w.Write([]byte(`{"id": "abc", "name": "Sales", "owner": {"id": 27}}`))
+	})
+	defer server.Close()
+	ctx := context.Background()
+
+	ds, _, err := client.Datasets.Update(ctx, "abc", DatasetUpdate{
+		Description: Ptr(""),
+		PDPEnabled:  Ptr(false),
+		Owner:       &Owner{ID: 27},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"description":"","pdpEnabled":false,"owner":{"id":27}}`
+	if body != expected {
+		t.Errorf("Expected body %s, got %s", expected, body)
+	}
+	if ds.Owner.ID != 27 {
+		t.Errorf("Unexpected dataset %+v", ds)
+	}
+
+	if _, _, err := client.Datasets.UpdateName(ctx, "abc", "Sales"); err != nil {
+		t.Fatal(err)
+	}
+	if body != `{"name":"Sales"}` {
+		t.Errorf("Expected only the name to be sent, got %s", body)
+	}
+
+	if _, _, err := client.Datasets.Update(ctx, "abc", DatasetUpdate{}); err == nil {
+		t.Error("Expected an error for an empty update")
+	}
+}
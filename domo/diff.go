@@ -0,0 +1,540 @@
+package domo
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RowChange kinds.
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// DiffSource is one side of a Diff: a dataset, a local CSV or a slice of structs.
+type DiffSource interface {
+	// Open returns the rows as CSV with a header row.
+	Open(ctx context.Context) (io.ReadCloser, error)
+	// Schema of the rows, or nil if only the column names in the header are known.
+	Schema(ctx context.Context) (*Schema, error)
+}
+
+// DatasetDiffSource diffs the data of a dataset, streamed from its export.
+func DatasetDiffSource(client *Client, id string) DiffSource {
+	return datasetDiffSource{client: client, id: id}
+}
+
+type datasetDiffSource struct {
+	client *Client
+	id     string
+}
+
+func (s datasetDiffSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := s.client.Datasets.ExportDataCSV(ctx, s.id, true, pw)
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+func (s datasetDiffSource) Schema(ctx context.Context) (*Schema, error) {
+	ds, _, err := s.client.Datasets.Info(ctx, s.id)
+	if err != nil {
+		return nil, err
+	}
+	return &ds.Schema, nil
+}
+
+// CSVDiffSource diffs CSV with a header row read from r, i.e. a local file. It can only be diffed once.
+func CSVDiffSource(r io.Reader) DiffSource {
+	return csvDiffSource{r: r}
+}
+
+type csvDiffSource struct {
+	r io.Reader
+}
+
+func (s csvDiffSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(s.r), nil
+}
+
+func (s csvDiffSource) Schema(ctx context.Context) (*Schema, error) {
+	return nil, nil
+}
+
+// RowsDiffSource diffs a slice of structs, serialized the same way MarshalCSV does.
+func RowsDiffSource[T any](rows []T) DiffSource {
+	return rowsDiffSource[T]{rows: rows}
+}
+
+type rowsDiffSource[T any] struct {
+	rows []T
+}
+
+func (s rowsDiffSource[T]) Open(ctx context.Context) (io.ReadCloser, error) {
+	schema, err := GenerateDataSetSchema(typeOf[T]())
+	if err != nil {
+		return nil, err
+	}
+	if len(s.rows) == 0 {
+		// MarshalCSV doesn't write a header without rows.
+		header := make([]string, len(schema.Columns))
+		for i, c := range schema.Columns {
+			header[i] = c.Name
+		}
+		return ioutil.NopCloser(strings.NewReader(strings.Join(header, ",") + "\n")), nil
+	}
+	data, err := MarshalCSV(s.rows, true)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(data)), nil
+}
+
+func (s rowsDiffSource[T]) Schema(ctx context.Context) (*Schema, error) {
+	schema, err := GenerateDataSetSchema(typeOf[T]())
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// DiffOptions configures Diff.
+type DiffOptions struct {
+	// KeyColumns identify a row on both sides. Required.
+	KeyColumns []string
+	// Partitions the rows are hashed into on disk by key. Only one partition of the old side is
+	// held in memory at a time. With 0 or 1 the whole old side is held in memory and nothing is
+	// written to disk.
+	Partitions int
+	// TempDir for the partition files, defaults to the system temp dir.
+	TempDir string
+	// OnChange is called with each added, removed and changed row. Returning an error stops the diff.
+	OnChange func(change RowChange) error
+}
+
+// RowChange is a row added, removed or changed between the old and new side of a Diff.
+type RowChange struct {
+	Kind string
+	// Key of the row, in the order of DiffOptions.KeyColumns.
+	Key []string
+	// Old row by column name, nil for added rows.
+	Old map[string]string
+	// New row by column name, nil for removed rows.
+	New map[string]string
+	// Columns that changed in a changed row.
+	Columns []ColumnChange
+}
+
+// ColumnChange is a changed cell of a changed row.
+type ColumnChange struct {
+	Column string
+	Old    string
+	New    string
+}
+
+// DiffResult summarizes a Diff.
+type DiffResult struct {
+	Added     int
+	Removed   int
+	Changed   int
+	Unchanged int
+	// Schema differences, with the old side as the Domo schema. Column types are only compared
+	// when both sides know their schema. Only the columns on both sides are diffed.
+	Schema SchemaDiffError
+}
+
+// Diff compares the rows of two sources by key columns, streaming each change to OnChange. Cells are
+// compared by their column's type from the sources' schemas, preferring the old side's: numbers by
+// value so 1 equals 1.0 in a DOUBLE column, DATE and DATETIME cells by time so they equal the same time
+// in another layout, and everything else byte for byte. Columns without a known type, i.e. when diffing
+// two CSVs, are compared byte for byte. Key cells are matched the same way.
+func Diff(ctx context.Context, old, new DiffSource, opts DiffOptions) (*DiffResult, error) {
+	if len(opts.KeyColumns) == 0 {
+		return nil, fmt.Errorf("diff needs at least one key column")
+	}
+	oldSide, err := openDiffSide(ctx, old, "old", opts.KeyColumns)
+	if err != nil {
+		return nil, err
+	}
+	defer oldSide.close()
+	newSide, err := openDiffSide(ctx, new, "new", opts.KeyColumns)
+	if err != nil {
+		return nil, err
+	}
+	defer newSide.close()
+
+	result := &DiffResult{}
+	oldSchema, oldTyped, err := oldSide.schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	newSchema, newTyped, err := newSide.schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]string)
+	for _, side := range []struct {
+		schema Schema
+		typed  bool
+	}{{newSchema, newTyped}, {oldSchema, oldTyped}} {
+		if side.typed {
+			for _, c := range side.schema.Columns {
+				types[c.Name] = c.ColumnType
+			}
+		}
+	}
+	oldSide.setKeyTypes(types)
+	newSide.setKeyTypes(types)
+	if !oldTyped || !newTyped {
+		oldSchema, newSchema = untypedSchema(oldSchema), untypedSchema(newSchema)
+	}
+	result.Schema = checkForSchemaChangeByColumnNameMatching(newSchema, oldSchema)
+
+	d := &differ{opts: opts, result: result, old: oldSide, new: newSide, types: types}
+	if opts.Partitions <= 1 {
+		return result, d.diff(oldSide.read, newSide.read)
+	}
+	return result, d.diffPartitioned()
+}
+
+// diffSide is an open DiffSource.
+type diffSide struct {
+	name   string
+	source DiffSource
+	rc     io.ReadCloser
+	r      *csv.Reader
+	header []string
+	keys   []int
+	// keyTypes are the column types of the keys, used to normalize key cells.
+	keyTypes []string
+}
+
+func openDiffSide(ctx context.Context, source DiffSource, name string, keyColumns []string) (*diffSide, error) {
+	rc, err := source.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &diffSide{name: name, source: source, rc: rc, r: csv.NewReader(rc)}
+	s.r.FieldsPerRecord = -1
+	if s.header, err = s.r.Read(); err != nil {
+		rc.Close()
+		if err == io.EOF {
+			err = fmt.Errorf("no header row")
+		}
+		return nil, fmt.Errorf("reading %s rows: %v", name, err)
+	}
+	for _, k := range keyColumns {
+		i := indexOf(s.header, k)
+		if i < 0 {
+			rc.Close()
+			return nil, fmt.Errorf("%s rows have no key column %q", name, k)
+		}
+		s.keys = append(s.keys, i)
+	}
+	return s, nil
+}
+
+func (s *diffSide) read() ([]string, error) {
+	record, err := s.r.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("reading %s rows: %v", s.name, err)
+	}
+	return record, err
+}
+
+// schema of the side, typed is false if only the header's column names are known.
+func (s *diffSide) schema(ctx context.Context) (schema Schema, typed bool, err error) {
+	known, err := s.source.Schema(ctx)
+	if err != nil {
+		return Schema{}, false, err
+	}
+	if known != nil {
+		return *known, true, nil
+	}
+	for _, h := range s.header {
+		schema.Columns = append(schema.Columns, Column{Name: h})
+	}
+	return schema, false, nil
+}
+
+func untypedSchema(schema Schema) Schema {
+	var untyped Schema
+	for _, c := range schema.Columns {
+		untyped.Columns = append(untyped.Columns, Column{Name: c.Name})
+	}
+	return untyped
+}
+
+func (s *diffSide) setKeyTypes(types map[string]string) {
+	s.keyTypes = make([]string, len(s.keys))
+	for i, k := range s.keys {
+		s.keyTypes[i] = types[s.header[k]]
+	}
+}
+
+// key returns the row's normalized key and its key cells.
+func (s *diffSide) key(record []string) (string, []string, error) {
+	cells := make([]string, len(s.keys))
+	normalized := make([]string, len(s.keys))
+	for i, k := range s.keys {
+		if k >= len(record) {
+			return "", nil, fmt.Errorf("%s row has %d columns, but %d are expected", s.name, len(record), len(s.header))
+		}
+		cells[i] = record[k]
+		normalized[i] = normalizeCell(s.keyTypes[i], record[k])
+	}
+	return strings.Join(normalized, "\x1f"), cells, nil
+}
+
+// normalizeCell returns the canonical form of a cell of the column type, so cells holding the same value
+// are equal, i.e. 1.50 and 1.5 in a DOUBLE column. STRING cells, cells of unknown type and cells that
+// don't parse as their type are returned unchanged.
+func normalizeCell(columnType, cell string) string {
+	switch columnType {
+	case ColumnTypeLong:
+		if i, err := strconv.ParseInt(cell, 10, 64); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+		if f, err := strconv.ParseFloat(cell, 64); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	case ColumnTypeDouble:
+		if f, err := strconv.ParseFloat(cell, 64); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	case ColumnTypeDecimal:
+		if r, ok := new(big.Rat).SetString(cell); ok {
+			return r.RatString()
+		}
+	case ColumnTypeDate, ColumnTypeDatetime:
+		if t, err := parseCSVTime(cell); err == nil {
+			return t.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return cell
+}
+
+func (s *diffSide) row(record []string) map[string]string {
+	row := make(map[string]string, len(s.header))
+	for i, h := range s.header {
+		if i < len(record) {
+			row[h] = record[i]
+		}
+	}
+	return row
+}
+
+func (s *diffSide) close() {
+	s.rc.Close()
+}
+
+type differ struct {
+	opts     DiffOptions
+	result   *DiffResult
+	old, new *diffSide
+	// types of the columns by name, empty when unknown.
+	types map[string]string
+}
+
+type oldRow struct {
+	index  int
+	key    []string
+	record []string
+	seen   bool
+}
+
+// diff compares the rows read from two iterators, holding the old rows in memory.
+func (d *differ) diff(readOld, readNew func() ([]string, error)) error {
+	oldRows := make(map[string]*oldRow)
+	for i := 0; ; i++ {
+		record, err := readOld()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		k, cells, err := d.old.key(record)
+		if err != nil {
+			return err
+		}
+		if _, dup := oldRows[k]; dup {
+			return fmt.Errorf("old rows have key %v more than once", cells)
+		}
+		oldRows[k] = &oldRow{index: i, key: cells, record: record}
+	}
+
+	seenNew := make(map[string]bool)
+	for {
+		record, err := readNew()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		k, cells, err := d.new.key(record)
+		if err != nil {
+			return err
+		}
+		if seenNew[k] {
+			return fmt.Errorf("new rows have key %v more than once", cells)
+		}
+		seenNew[k] = true
+		o, ok := oldRows[k]
+		if !ok {
+			d.result.Added++
+			if err := d.emit(RowChange{Kind: DiffAdded, Key: cells, New: d.new.row(record)}); err != nil {
+				return err
+			}
+			continue
+		}
+		o.seen = true
+		oldRow, newRow := d.old.row(o.record), d.new.row(record)
+		var changes []ColumnChange
+		for _, h := range d.old.header {
+			n, ok := newRow[h]
+			if ok && normalizeCell(d.types[h], oldRow[h]) != normalizeCell(d.types[h], n) {
+				changes = append(changes, ColumnChange{Column: h, Old: oldRow[h], New: n})
+			}
+		}
+		if len(changes) == 0 {
+			d.result.Unchanged++
+			continue
+		}
+		d.result.Changed++
+		if err := d.emit(RowChange{Kind: DiffChanged, Key: cells, Old: oldRow, New: newRow, Columns: changes}); err != nil {
+			return err
+		}
+	}
+
+	var removed []*oldRow
+	for _, o := range oldRows {
+		if !o.seen {
+			removed = append(removed, o)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].index < removed[j].index })
+	for _, o := range removed {
+		d.result.Removed++
+		if err := d.emit(RowChange{Kind: DiffRemoved, Key: o.key, Old: d.old.row(o.record)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffPartitioned hashes both sides into partition files by key and diffs each partition in turn.
+func (d *differ) diffPartitioned() error {
+	dir, err := ioutil.TempDir(d.opts.TempDir, "domo-diff")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	oldFiles, err := d.partition(dir, d.old)
+	if err != nil {
+		return err
+	}
+	newFiles, err := d.partition(dir, d.new)
+	if err != nil {
+		return err
+	}
+	for i := range oldFiles {
+		if err := d.diffPartition(oldFiles[i], newFiles[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *differ) diffPartition(oldPath, newPath string) error {
+	oldFile, err := os.Open(oldPath)
+	if err != nil {
+		return err
+	}
+	defer oldFile.Close()
+	newFile, err := os.Open(newPath)
+	if err != nil {
+		return err
+	}
+	defer newFile.Close()
+	oldReader, newReader := csv.NewReader(oldFile), csv.NewReader(newFile)
+	oldReader.FieldsPerRecord, newReader.FieldsPerRecord = -1, -1
+	return d.diff(oldReader.Read, newReader.Read)
+}
+
+func (d *differ) partition(dir string, side *diffSide) ([]string, error) {
+	paths := make([]string, d.opts.Partitions)
+	files := make([]*os.File, d.opts.Partitions)
+	writers := make([]*csv.Writer, d.opts.Partitions)
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	for i := range files {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("%s-%d.csv", side.name, i))
+		f, err := os.Create(paths[i])
+		if err != nil {
+			return nil, err
+		}
+		files[i], writers[i] = f, csv.NewWriter(f)
+	}
+	for {
+		record, err := side.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		k, _, err := side.key(record)
+		if err != nil {
+			return nil, err
+		}
+		h := fnv.New32a()
+		h.Write([]byte(k))
+		if err := writers[h.Sum32()%uint32(len(writers))].Write(record); err != nil {
+			return nil, err
+		}
+	}
+	for _, w := range writers {
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+func (d *differ) emit(change RowChange) error {
+	if d.opts.OnChange == nil {
+		return nil
+	}
+	return d.opts.OnChange(change)
+}
+
+func indexOf(values []string, v string) int {
+	for i, s := range values {
+		if s == v {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,176 @@
+package domo
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type diffRow struct {
+	ID     int     `domo:"id"`
+	Region string  `domo:"region"`
+	Amount float64 `domo:"amount"`
+}
+
+func collectChanges(changes *[]RowChange) func(RowChange) error {
+	return func(c RowChange) error {
+		*changes = append(*changes, c)
+		return nil
+	}
+}
+
+func TestDiff_DatasetAndRows(t *testing.T) {
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/datasets/abc":
+			w.Write([]byte(`{"id": "abc", "schema": {"columns": [
+				{"type": "LONG", "name": "id"}, {"type": "STRING", "name": "region"}, {"type": "LONG", "name": "amount"}]}}`))
+		case "/v1/datasets/abc/data":
+			w.Write([]byte("id,region,amount\n1,West,10\n2,East,20\n3,North,30\n"))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer server.Close()
+
+	var changes []RowChange
+	rows := []diffRow{{1, "West", 10.0}, {2, "East", 25}, {4, "South", 40}}
+	result, err := Diff(context.Background(), DatasetDiffSource(client, "abc"), RowsDiffSource(rows), DiffOptions{
+		KeyColumns: []string{"id"},
+		OnChange:   collectChanges(&changes),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 1 || result.Removed != 1 || result.Changed != 1 || result.Unchanged != 1 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %+v", changes)
+	}
+	changed := changes[0]
+	if changed.Kind != DiffChanged || changed.Key[0] != "2" || len(changed.Columns) != 1 ||
+		changed.Columns[0] != (ColumnChange{Column: "amount", Old: "20", New: "25"}) {
+		t.Errorf("Unexpected change %+v", changed)
+	}
+	if changes[1].Kind != DiffAdded || changes[1].New["region"] != "South" || changes[1].Old != nil {
+		t.Errorf("Unexpected added row %+v", changes[1])
+	}
+	if changes[2].Kind != DiffRemoved || changes[2].Old["region"] != "North" || changes[2].New != nil {
+		t.Errorf("Unexpected removed row %+v", changes[2])
+	}
+	if len(result.Schema.ColumnTypeMismatch) != 1 || result.Schema.ColumnTypeMismatch[0].DomoColumnName != "amount" {
+		t.Errorf("Expected the amount column type change, got %+v", result.Schema)
+	}
+}
+
+func TestDiff_Partitioned(t *testing.T) {
+	oldCSV := "id,name,extra\n"
+	newCSV := "name,id\n"
+	for i := 0; i < 100; i++ {
+		name := string(rune('a' + i%26))
+		oldCSV += strings.Join([]string{strconv.Itoa(i), name, "x"}, ",") + "\n"
+		if i%10 == 0 {
+			name += "!"
+		}
+		if i%7 != 0 {
+			newCSV += name + "," + strconv.Itoa(i) + "\n"
+		}
+	}
+	newCSV += "new,1000\n"
+
+	summaries := make(map[int]string)
+	for _, partitions := range []int{0, 8} {
+		var changes []RowChange
+		result, err := Diff(context.Background(), CSVDiffSource(strings.NewReader(oldCSV)), CSVDiffSource(strings.NewReader(newCSV)), DiffOptions{
+			KeyColumns: []string{"id"},
+			Partitions: partitions,
+			OnChange:   collectChanges(&changes),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Added != 1 || result.Removed != 15 || result.Changed != 8 || result.Unchanged != 77 {
+			t.Errorf("Unexpected result with %d partitions %+v", partitions, result)
+		}
+		if len(result.Schema.ColumnsToDeleteFromDomo) != 1 || len(result.Schema.ColumnTypeMismatch) != 0 {
+			t.Errorf("Expected only the extra column to be reported, got %+v", result.Schema)
+		}
+		var keys []string
+		for _, c := range changes {
+			keys = append(keys, c.Kind+c.Key[0])
+		}
+		sort.Strings(keys)
+		summaries[partitions] = strings.Join(keys, " ")
+	}
+	if summaries[0] != summaries[8] {
+		t.Errorf("Expected the same changes with and without partitions:\n%s\n%s", summaries[0], summaries[8])
+	}
+}
+
+// typedCSVDiffSource is CSV with a known schema.
+type typedCSVDiffSource struct {
+	csv    string
+	schema Schema
+}
+
+func (s typedCSVDiffSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(s.csv)), nil
+}
+
+func (s typedCSVDiffSource) Schema(ctx context.Context) (*Schema, error) {
+	return &s.schema, nil
+}
+
+func TestDiff_ColumnTypes(t *testing.T) {
+	schema := Schema{Columns: []Column{
+		{ColumnType: ColumnTypeLong, Name: "id"},
+		{ColumnType: ColumnTypeString, Name: "code"},
+		{ColumnType: ColumnTypeDouble, Name: "price"},
+		{ColumnType: ColumnTypeDatetime, Name: "at"},
+	}}
+	old := typedCSVDiffSource{schema: schema, csv: "id,code,price,at\n" +
+		"1,01234,1.50,2019-03-04T15:04:05Z\n" +
+		"2,1e3,2,2019-03-04 15:04:05\n" +
+		"3,x,3,\n"}
+	new := typedCSVDiffSource{schema: schema, csv: "id,code,price,at\n" +
+		"1.0,1234,1.5,2019-03-04T15:04:05.000Z\n" +
+		"2,1000,2.0,2019-03-04T15:04:05Z\n" +
+		"03,x,3,\n"}
+
+	var changes []RowChange
+	result, err := Diff(context.Background(), old, new, DiffOptions{KeyColumns: []string{"id"}, OnChange: collectChanges(&changes)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 0 || result.Removed != 0 || result.Changed != 2 || result.Unchanged != 1 {
+		t.Fatalf("Expected LONG keys to match by value and only the STRING codes to change, got %+v %+v", result, changes)
+	}
+	for i, c := range changes {
+		if len(c.Columns) != 1 || c.Columns[0].Column != "code" {
+			t.Errorf("Expected change %d to only be the code, got %+v", i, c.Columns)
+		}
+	}
+	if changes[0].Key[0] != "1.0" {
+		t.Errorf("Expected the new side's key cells, got %v", changes[0].Key)
+	}
+}
+
+func TestDiff_Errors(t *testing.T) {
+	ctx := context.Background()
+	src := func(csv string) DiffSource { return CSVDiffSource(strings.NewReader(csv)) }
+	if _, err := Diff(ctx, src("id\n1\n1\n"), src("id\n"), DiffOptions{KeyColumns: []string{"id"}}); err == nil {
+		t.Error("Expected an error for a duplicate key")
+	}
+	if _, err := Diff(ctx, src("id\n1\n"), src("key\n1\n"), DiffOptions{KeyColumns: []string{"id"}}); err == nil {
+		t.Error("Expected an error for a missing key column")
+	}
+	if _, err := Diff(ctx, src("id\n1\n"), src("id\n1\n"), DiffOptions{}); err == nil {
+		t.Error("Expected an error without key columns")
+	}
+}
@@ -0,0 +1,602 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Defaults used by DirectorySync.
+const (
+	// DefaultDirectoryRole is the role of users created by a DirectorySync that have none in the directory.
+	DefaultDirectoryRole = "Participant"
+	// DefaultMaxDeletePercent is the largest share of Domo users a DirectorySync may delete in a run.
+	DefaultMaxDeletePercent = 10
+)
+
+// Actions of a DirectoryChange.
+const (
+	DirectoryCreateUser   = "create_user"
+	DirectoryUpdateUser   = "update_user"
+	DirectoryDeleteUser   = "delete_user"
+	DirectoryCreateGroup  = "create_group"
+	DirectoryAddMember    = "add_member"
+	DirectoryRemoveMember = "remove_member"
+)
+
+// DirectoryUser is a user in an identity source, i.e. an HR system. Empty fields aren't synced, so
+// a source only manages the attributes it has.
+type DirectoryUser struct {
+	Email          string
+	EmployeeNumber int
+	Name           string
+	Title          string
+	Role           string
+	Phone          string
+	Location       string
+	Timezone       string
+	// Groups are the names of the Domo groups the user is a member of.
+	Groups []string
+}
+
+// DirectorySource yields the users, and their group memberships, a DirectorySync makes Domo match.
+type DirectorySource interface {
+	Users(ctx context.Context) ([]DirectoryUser, error)
+}
+
+// MemoryDirectory is a DirectorySource of a fixed list of users.
+type MemoryDirectory []DirectoryUser
+
+// Users in the directory.
+func (d MemoryDirectory) Users(ctx context.Context) ([]DirectoryUser, error) {
+	return append([]DirectoryUser(nil), d...), nil
+}
+
+// CSVDirectory is a DirectorySource reading users from a CSV file with a header row, read again on
+// every sync. The recognized columns are email, employeeNumber, name, title, role, phone, location,
+// timezone and groups, others are ignored. Group names in the groups column are separated by
+// GroupSeparator.
+type CSVDirectory struct {
+	Path string
+	// GroupSeparator separates group names in the groups column. Defaults to ";".
+	GroupSeparator string
+}
+
+// directoryCSVRow is a row of a CSVDirectory.
+type directoryCSVRow struct {
+	Email          string `domo:"email"`
+	EmployeeNumber int    `domo:"employeeNumber"`
+	Name           string `domo:"name"`
+	Title          string `domo:"title"`
+	Role           string `domo:"role"`
+	Phone          string `domo:"phone"`
+	Location       string `domo:"location"`
+	Timezone       string `domo:"timezone"`
+	Groups         string `domo:"groups"`
+}
+
+// Users in the file.
+func (d CSVDirectory) Users(ctx context.Context) ([]DirectoryUser, error) {
+	data, err := ioutil.ReadFile(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	var rows []directoryCSVRow
+	if err := UnmarshalCSV(string(data), &rows); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", d.Path, err)
+	}
+	separator := d.GroupSeparator
+	if separator == "" {
+		separator = ";"
+	}
+	users := make([]DirectoryUser, len(rows))
+	for i, row := range rows {
+		users[i] = DirectoryUser{
+			Email:          strings.TrimSpace(row.Email),
+			EmployeeNumber: row.EmployeeNumber,
+			Name:           row.Name,
+			Title:          row.Title,
+			Role:           row.Role,
+			Phone:          row.Phone,
+			Location:       row.Location,
+			Timezone:       row.Timezone,
+		}
+		for _, group := range strings.Split(row.Groups, separator) {
+			if group = strings.TrimSpace(group); group != "" {
+				users[i].Groups = append(users[i].Groups, group)
+			}
+		}
+	}
+	return users, nil
+}
+
+// DirectorySync makes Domo's users, and the members of the groups named in the directory, match a
+// DirectorySource. Directory users are matched to Domo users by employee number, then by email. Unmatched
+// directory users are created, matched ones updated and managed Domo users missing from the directory
+// deleted. Groups the directory doesn't name are left alone, as are protected and unmanaged members of the
+// groups it does name.
+type DirectorySync struct {
+	Client *Client
+	Source DirectorySource
+	// DefaultRole of created users that have no role in the directory. Defaults to DefaultDirectoryRole.
+	DefaultRole string
+	// SendInvite emails created users an invite.
+	SendInvite bool
+	// DeleteUsers deletes managed Domo users that aren't in the directory.
+	DeleteUsers bool
+	// Managed, if set, limits the Domo users that are deleted when missing from the directory, and counted
+	// against MaxDeletePercent. Defaults to every user.
+	Managed func(u *User) bool
+	// ProtectedUserIDs are never deleted, i.e. service accounts.
+	ProtectedUserIDs []int
+	// MaxDeletePercent aborts the sync before making any change if more than this percentage of the managed
+	// users would be deleted, or of a synced group's members would be removed. 0 allows no deletes and 100
+	// disables the limit. A negative value uses DefaultMaxDeletePercent, which NewDirectorySync sets.
+	MaxDeletePercent float64
+	// SyncGroups adds the directory users to the groups named in the directory.
+	SyncGroups bool
+	// RemoveMembers also removes the managed members of synced groups that the directory doesn't list in
+	// them. Protected and unmanaged members are never removed.
+	RemoveMembers bool
+	// CreateGroups creates groups named in the directory that don't exist in Domo.
+	CreateGroups bool
+	// Concurrency of group membership changes. Defaults to DefaultSyncConcurrency.
+	Concurrency int
+	// DryRun reports the changes without making them.
+	DryRun bool
+
+	now func() time.Time
+}
+
+// NewDirectorySync returns a DirectorySync of the users in source, deleting at most DefaultMaxDeletePercent.
+func NewDirectorySync(client *Client, source DirectorySource) *DirectorySync {
+	return &DirectorySync{Client: client, Source: source, MaxDeletePercent: DefaultMaxDeletePercent}
+}
+
+// DirectoryChange is a change made, or on a dry run planned, by a DirectorySync.
+type DirectoryChange struct {
+	Action         string `json:"action"`
+	UserID         int    `json:"userId,omitempty"`
+	Email          string `json:"email,omitempty"`
+	EmployeeNumber int    `json:"employeeNumber,omitempty"`
+	GroupID        int    `json:"groupId,omitempty"`
+	Group          string `json:"group,omitempty"`
+	// Fields changed by an update.
+	Fields []string `json:"fields,omitempty"`
+	// Error is set when the change failed.
+	Error string `json:"error,omitempty"`
+}
+
+// DirectorySyncReport is the audit trail of a DirectorySync run.
+type DirectorySyncReport struct {
+	DryRun     bool              `json:"dryRun"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt time.Time         `json:"finishedAt"`
+	Changes    []DirectoryChange `json:"changes"`
+	// UnchangedUsers is the number of directory users already matching their Domo user.
+	UnchangedUsers int `json:"unchangedUsers"`
+}
+
+// Failed returns the changes that failed.
+func (r *DirectorySyncReport) Failed() []DirectoryChange {
+	var failed []DirectoryChange
+	for _, c := range r.Changes {
+		if c.Error != "" {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Count returns the number of changes with the action.
+func (r *DirectorySyncReport) Count(action string) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// DirectorySyncLimitError is returned by DirectorySync.Run when it would delete more users, or remove more
+// members of a group, than its MaxDeletePercent allows. No changes are made.
+type DirectorySyncLimitError struct {
+	// Group is the name of the group whose members would be removed, empty for user deletes.
+	Group   string
+	Deletes int
+	// Managed is the number of managed users, or of the group's members.
+	Managed          int
+	MaxDeletePercent float64
+}
+
+func (e DirectorySyncLimitError) Error() string {
+	if e.Group != "" {
+		return fmt.Sprintf("directory sync would remove %d of %d members of group %s, more than the %g%% allowed", e.Deletes, e.Managed, e.Group, e.MaxDeletePercent)
+	}
+	return fmt.Sprintf("directory sync would delete %d of %d users, more than the %g%% allowed", e.Deletes, e.Managed, e.MaxDeletePercent)
+}
+
+// directoryPlan is a user change a DirectorySync will make.
+type directoryPlan struct {
+	entry  DirectoryUser
+	user   *User
+	change DirectoryChange
+}
+
+// directoryGroupPlan is a group whose members a DirectorySync will sync.
+type directoryGroupPlan struct {
+	name string
+	// group is nil when it doesn't exist yet.
+	group   *Group
+	members []*directoryPlan
+	// kept are the current members that stay in the group though the directory doesn't list them.
+	kept []int
+}
+
+// Run the sync once. Failed changes are recorded in the report instead of stopping the sync. The error is
+// set when the directory or Domo's users can't be read, or when a DirectorySyncLimitError stops the sync,
+// in which case the report lists the changes that would have been made.
+func (d *DirectorySync) Run(ctx context.Context) (*DirectorySyncReport, error) {
+	now := time.Now
+	if d.now != nil {
+		now = d.now
+	}
+	report := &DirectorySyncReport{DryRun: d.DryRun, StartedAt: now().UTC()}
+	defer func() { report.FinishedAt = now().UTC() }()
+
+	entries, err := d.Source.Users(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory: %v", err)
+	}
+	users, err := d.Client.Users.AllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plans, deletes, err := d.plan(entries, users, report)
+	if err != nil {
+		return report, err
+	}
+	var groupPlans []*directoryGroupPlan
+	if d.SyncGroups {
+		if groupPlans, err = d.planGroups(ctx, plans, users, report); err != nil {
+			return report, err
+		}
+	}
+	for _, p := range plans {
+		d.applyUser(ctx, p, report)
+	}
+	d.syncGroups(ctx, groupPlans, report)
+	// deleted last so the users are removed from synced groups first.
+	for _, u := range deletes {
+		change := DirectoryChange{Action: DirectoryDeleteUser, UserID: u.ID, Email: u.Email, EmployeeNumber: u.EmployeeNumber}
+		if !d.DryRun {
+			if _, err := d.Client.Users.Delete(ctx, u.ID); err != nil {
+				change.Error = err.Error()
+			}
+		}
+		report.Changes = append(report.Changes, change)
+	}
+	return report, nil
+}
+
+// plan matches the directory to Domo's users, returning the creates and updates, or unchanged users, and
+// the deletes. Invalid directory entries are recorded as failed creates.
+func (d *DirectorySync) plan(entries []DirectoryUser, users []*User, report *DirectorySyncReport) ([]*directoryPlan, []*User, error) {
+	byEmail := make(map[string]*User, len(users))
+	byEmployeeNumber := make(map[int]*User, len(users))
+	for _, u := range users {
+		if u.Email != "" {
+			byEmail[strings.ToLower(u.Email)] = u
+		}
+		if u.EmployeeNumber != 0 {
+			byEmployeeNumber[u.EmployeeNumber] = u
+		}
+	}
+
+	var plans []*directoryPlan
+	matched := make(map[int]string, len(entries))
+	for _, entry := range entries {
+		change := DirectoryChange{Email: entry.Email, EmployeeNumber: entry.EmployeeNumber}
+		user := byEmployeeNumber[entry.EmployeeNumber]
+		if user == nil && entry.Email != "" {
+			user = byEmail[strings.ToLower(entry.Email)]
+		}
+		switch {
+		case entry.Email == "" && entry.EmployeeNumber == 0:
+			change.Action, change.Error = DirectoryCreateUser, "directory user has no email or employee number"
+		case user == nil && entry.Email == "":
+			change.Action, change.Error = DirectoryCreateUser, "directory user has no email to create a user with"
+		case user == nil:
+			change.Action = DirectoryCreateUser
+		case matched[user.ID] != "":
+			change.Action, change.UserID = DirectoryUpdateUser, user.ID
+			change.Error = fmt.Sprintf("user %d already matched directory user %s", user.ID, matched[user.ID])
+		default:
+			matched[user.ID] = entry.Email
+			if matched[user.ID] == "" {
+				matched[user.ID] = fmt.Sprintf("#%d", entry.EmployeeNumber)
+			}
+			change.Action, change.UserID = DirectoryUpdateUser, user.ID
+			change.Fields = directoryUserChanges(entry, user)
+			if len(change.Fields) == 0 {
+				report.UnchangedUsers++
+			}
+		}
+		if change.Error != "" {
+			report.Changes = append(report.Changes, change)
+			continue
+		}
+		plans = append(plans, &directoryPlan{entry: entry, user: user, change: change})
+	}
+
+	if !d.DeleteUsers {
+		return plans, nil, nil
+	}
+	var deletes []*User
+	managed := 0
+	for _, u := range users {
+		if !d.manages(u) {
+			continue
+		}
+		managed++
+		if matched[u.ID] == "" {
+			deletes = append(deletes, u)
+		}
+	}
+	maxPercent := d.maxDeletePercent()
+	if exceedsPercent(len(deletes), managed, maxPercent) {
+		for _, p := range plans {
+			if p.change.Action == DirectoryCreateUser || len(p.change.Fields) > 0 {
+				report.Changes = append(report.Changes, p.change)
+			}
+		}
+		for _, u := range deletes {
+			report.Changes = append(report.Changes, DirectoryChange{Action: DirectoryDeleteUser, UserID: u.ID, Email: u.Email, EmployeeNumber: u.EmployeeNumber})
+		}
+		return nil, nil, DirectorySyncLimitError{Deletes: len(deletes), Managed: managed, MaxDeletePercent: maxPercent}
+	}
+	return plans, deletes, nil
+}
+
+// manages reports whether the user may be deleted or removed from groups, i.e. it's neither protected
+// nor excluded by Managed.
+func (d *DirectorySync) manages(u *User) bool {
+	for _, id := range d.ProtectedUserIDs {
+		if u.ID == id {
+			return false
+		}
+	}
+	return d.Managed == nil || d.Managed(u)
+}
+
+func (d *DirectorySync) maxDeletePercent() float64 {
+	if d.MaxDeletePercent < 0 {
+		return DefaultMaxDeletePercent
+	}
+	return d.MaxDeletePercent
+}
+
+// exceedsPercent reports whether deleting n of total is more than maxPercent.
+func exceedsPercent(n, total int, maxPercent float64) bool {
+	return n > 0 && float64(n) > float64(total)*maxPercent/100
+}
+
+// directoryUserChanges returns the fields of user the directory entry changes.
+func directoryUserChanges(entry DirectoryUser, user *User) []string {
+	var fields []string
+	changed := func(field, want, have string) {
+		if want != "" && want != have {
+			fields = append(fields, field)
+		}
+	}
+	if entry.Email != "" && !strings.EqualFold(entry.Email, user.Email) {
+		fields = append(fields, "email")
+	}
+	if entry.EmployeeNumber != 0 && entry.EmployeeNumber != user.EmployeeNumber {
+		fields = append(fields, "employeeNumber")
+	}
+	changed("name", entry.Name, user.Name)
+	changed("title", entry.Title, user.Title)
+	changed("role", entry.Role, user.Role)
+	changed("phone", entry.Phone, user.Phone)
+	changed("location", entry.Location, user.Location)
+	changed("timezone", entry.Timezone, user.Timezone)
+	return fields
+}
+
+// applyUser creates or updates the planned user, recording the change.
+func (d *DirectorySync) applyUser(ctx context.Context, p *directoryPlan, report *DirectorySyncReport) {
+	entry := p.entry
+	if p.change.Action == DirectoryUpdateUser && len(p.change.Fields) == 0 {
+		return
+	}
+
+	var user User
+	if p.user != nil {
+		// Domo requires every field on update, so the entry is applied over the current user.
+		user = *p.user
+	} else {
+		user.Role = d.DefaultRole
+		if user.Role == "" {
+			user.Role = DefaultDirectoryRole
+		}
+		user.Name = entry.Email
+	}
+	set := func(field *string, v string) {
+		if v != "" {
+			*field = v
+		}
+	}
+	set(&user.Email, entry.Email)
+	set(&user.Name, entry.Name)
+	set(&user.Title, entry.Title)
+	set(&user.Role, entry.Role)
+	set(&user.Phone, entry.Phone)
+	set(&user.Location, entry.Location)
+	set(&user.Timezone, entry.Timezone)
+	if entry.EmployeeNumber != 0 {
+		user.EmployeeNumber = entry.EmployeeNumber
+	}
+
+	if !d.DryRun {
+		var err error
+		if p.user != nil {
+			_, err = d.Client.Users.Update(ctx, user)
+		} else {
+			var created *User
+			if created, _, err = d.Client.Users.Create(ctx, user, d.SendInvite); err == nil {
+				p.user = created
+				p.change.UserID = created.ID
+			}
+		}
+		if err != nil {
+			p.change.Error = err.Error()
+		}
+	}
+	report.Changes = append(report.Changes, p.change)
+}
+
+// planGroups finds the groups named in the directory and which of their current members stay. It returns
+// a DirectorySyncLimitError if a group would lose more members than MaxDeletePercent allows.
+func (d *DirectorySync) planGroups(ctx context.Context, plans []*directoryPlan, users []*User, report *DirectorySyncReport) ([]*directoryGroupPlan, error) {
+	members := make(map[string][]*directoryPlan)
+	for _, p := range plans {
+		for _, group := range p.entry.Groups {
+			members[group] = append(members[group], p)
+		}
+	}
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups, err := d.allGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	var groupPlans []*directoryGroupPlan
+	for _, name := range names {
+		gp := &directoryGroupPlan{name: name, group: groups[name], members: members[name]}
+		groupPlans = append(groupPlans, gp)
+		if gp.group == nil {
+			continue
+		}
+		current, err := d.Client.Groups.AllUserIDs(ctx, gp.group.ID)
+		if err != nil {
+			return nil, err
+		}
+		listed := make(map[int]bool, len(gp.members))
+		for _, p := range gp.members {
+			if p.user != nil {
+				listed[p.user.ID] = true
+			}
+		}
+		var removes []int
+		for _, id := range current {
+			switch {
+			case listed[id]:
+				continue
+			// members Domo doesn't list as users can't be checked, so they're treated as managed.
+			case d.RemoveMembers && (byID[id] == nil || d.manages(byID[id])):
+				removes = append(removes, id)
+			default:
+				gp.kept = append(gp.kept, id)
+			}
+		}
+		if maxPercent := d.maxDeletePercent(); exceedsPercent(len(removes), len(current), maxPercent) {
+			for _, id := range removes {
+				report.Changes = append(report.Changes, DirectoryChange{Action: DirectoryRemoveMember, UserID: id, GroupID: gp.group.ID, Group: name})
+			}
+			return nil, DirectorySyncLimitError{Group: name, Deletes: len(removes), Managed: len(current), MaxDeletePercent: maxPercent}
+		}
+	}
+	return groupPlans, nil
+}
+
+// syncGroups syncs the members of every planned group.
+func (d *DirectorySync) syncGroups(ctx context.Context, groupPlans []*directoryGroupPlan, report *DirectorySyncReport) {
+	for _, gp := range groupPlans {
+		name, group := gp.name, gp.group
+		if group == nil {
+			change := DirectoryChange{Action: DirectoryCreateGroup, Group: name}
+			if !d.CreateGroups {
+				change.Error = "group doesn't exist"
+			} else if !d.DryRun {
+				var err error
+				if group, _, err = d.Client.Groups.Create(ctx, Group{Name: name}); err != nil {
+					change.Error = err.Error()
+				} else {
+					change.GroupID = group.ID
+				}
+			}
+			report.Changes = append(report.Changes, change)
+			if change.Error != "" {
+				continue
+			}
+		}
+
+		// users not created yet, on a dry run or because their create failed, can't be added.
+		var userIDs []int
+		for _, p := range gp.members {
+			if p.user == nil {
+				change := DirectoryChange{Action: DirectoryAddMember, Email: p.entry.Email, EmployeeNumber: p.entry.EmployeeNumber, Group: name}
+				if !d.DryRun {
+					change.Error = "user wasn't created"
+				}
+				report.Changes = append(report.Changes, change)
+				continue
+			}
+			userIDs = append(userIDs, p.user.ID)
+		}
+		if group == nil {
+			// a group created on a dry run only gains members.
+			for _, id := range userIDs {
+				report.Changes = append(report.Changes, DirectoryChange{Action: DirectoryAddMember, UserID: id, Group: name})
+			}
+			continue
+		}
+
+		userIDs = append(userIDs, gp.kept...)
+		sync, err := d.Client.Groups.SyncMembers(ctx, group.ID, userIDs, SyncMembersOptions{Concurrency: d.Concurrency, DryRun: d.DryRun})
+		if err != nil {
+			report.Changes = append(report.Changes, DirectoryChange{Action: DirectoryAddMember, GroupID: group.ID, Group: name, Error: err.Error()})
+			continue
+		}
+		for _, id := range sync.Added {
+			report.Changes = append(report.Changes, DirectoryChange{Action: DirectoryAddMember, UserID: id, GroupID: group.ID, Group: name})
+		}
+		for _, id := range sync.Removed {
+			report.Changes = append(report.Changes, DirectoryChange{Action: DirectoryRemoveMember, UserID: id, GroupID: group.ID, Group: name})
+		}
+		for _, f := range sync.Failed {
+			action := DirectoryAddMember
+			if f.Removing {
+				action = DirectoryRemoveMember
+			}
+			report.Changes = append(report.Changes, DirectoryChange{Action: action, UserID: f.UserID, GroupID: group.ID, Group: name, Error: f.Err.Error()})
+		}
+	}
+}
+
+// allGroups pages through the groups, returning them by name.
+func (d *DirectorySync) allGroups(ctx context.Context) (map[string]*Group, error) {
+	groups := make(map[string]*Group)
+	err := eachPaged(ctx, d.Client.Groups.List, func(g *Group) bool {
+		groups[g.Name] = g
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
@@ -0,0 +1,310 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// directoryTestServer serves users and groups, recording every change made to them.
+type directoryTestServer struct {
+	t       *testing.T
+	mu      sync.Mutex
+	users   map[int]*User
+	groups  map[int]*Group
+	members map[int]map[int]bool
+	nextID  int
+	changes []string
+}
+
+func newDirectoryTestServer(t *testing.T) *directoryTestServer {
+	return &directoryTestServer{
+		t: t,
+		users: map[int]*User{
+			1: {ID: 1, Name: "Alice", Email: "alice@example.com", EmployeeNumber: 100, Role: "Editor"},
+			2: {ID: 2, Name: "Bob", Email: "Bob@example.com", Title: "Analyst", Role: "Participant"},
+			3: {ID: 3, Name: "Carol", Email: "carol@example.com", Role: "Participant"},
+			4: {ID: 4, Name: "ETL", Email: "etl@example.com", Role: "Admin"},
+		},
+		groups:  map[int]*Group{9: {ID: 9, Name: "Sales"}},
+		members: map[int]map[int]bool{9: {1: true, 3: true}},
+		nextID:  20,
+	}
+}
+
+func (d *directoryTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	testRouter{t: d.t, routes: []testRoute{
+		{"GET /v1/users", func(w http.ResponseWriter, r *http.Request) {
+			users := []*User{}
+			for _, u := range d.users {
+				users = append(users, u)
+			}
+			sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+			json.NewEncoder(w).Encode(users)
+		}},
+		{"POST /v1/users", func(w http.ResponseWriter, r *http.Request) {
+			var u User
+			json.NewDecoder(r.Body).Decode(&u)
+			d.nextID++
+			u.ID = d.nextID
+			d.users[u.ID] = &u
+			d.changes = append(d.changes, fmt.Sprintf("create user %d %s %s %s", u.ID, u.Email, u.Name, u.Role))
+			json.NewEncoder(w).Encode(u)
+		}},
+		{"PUT /v1/users/*", func(w http.ResponseWriter, r *http.Request) {
+			id := pathID(r, 3)
+			var u User
+			json.NewDecoder(r.Body).Decode(&u)
+			d.users[id] = &u
+			d.changes = append(d.changes, fmt.Sprintf("update user %d %s %s %d %s", id, u.Email, u.Name, u.EmployeeNumber, u.Title))
+		}},
+		{"DELETE /v1/users/*", func(w http.ResponseWriter, r *http.Request) {
+			id := pathID(r, 3)
+			delete(d.users, id)
+			d.changes = append(d.changes, fmt.Sprintf("delete user %d", id))
+		}},
+		{"GET /v1/groups", func(w http.ResponseWriter, r *http.Request) {
+			groups := []*Group{}
+			for _, g := range d.groups {
+				groups = append(groups, g)
+			}
+			json.NewEncoder(w).Encode(groups)
+		}},
+		{"POST /v1/groups", func(w http.ResponseWriter, r *http.Request) {
+			var g Group
+			json.NewDecoder(r.Body).Decode(&g)
+			d.nextID++
+			g.ID = d.nextID
+			d.groups[g.ID] = &g
+			d.members[g.ID] = map[int]bool{}
+			d.changes = append(d.changes, fmt.Sprintf("create group %d %s", g.ID, g.Name))
+			json.NewEncoder(w).Encode(g)
+		}},
+		{"GET /v1/groups/*/users", func(w http.ResponseWriter, r *http.Request) {
+			ids := []int{}
+			for userID := range d.members[pathID(r, 3)] {
+				ids = append(ids, userID)
+			}
+			json.NewEncoder(w).Encode(ids)
+		}},
+		{"PUT /v1/groups/*/users/*", func(w http.ResponseWriter, r *http.Request) {
+			id, userID := pathID(r, 3), pathID(r, 5)
+			d.changes = append(d.changes, fmt.Sprintf("PUT group %d user %d", id, userID))
+			d.members[id][userID] = true
+		}},
+		{"DELETE /v1/groups/*/users/*", func(w http.ResponseWriter, r *http.Request) {
+			id, userID := pathID(r, 3), pathID(r, 5)
+			d.changes = append(d.changes, fmt.Sprintf("DELETE group %d user %d", id, userID))
+			delete(d.members[id], userID)
+		}},
+	}}.ServeHTTP(w, r)
+}
+
+// pathID returns the integer path segment i of the request, counting the empty segment before the
+// leading slash as 0.
+func pathID(r *http.Request, i int) int {
+	id, _ := strconv.Atoi(strings.Split(r.URL.Path, "/")[i])
+	return id
+}
+
+var testDirectory = MemoryDirectory{
+	{Email: "alice.smith@example.com", EmployeeNumber: 100, Groups: []string{"Sales"}},
+	{Email: "bob@example.com", Title: "Manager", Groups: []string{"Sales"}},
+	{Email: "dave@example.com", Name: "Dave", Groups: []string{"Ops"}},
+}
+
+func TestDirectorySync_Run(t *testing.T) {
+	d := newDirectoryTestServer(t)
+	// the protected ETL user and an unmanaged partner are members of Sales but not in the directory.
+	d.users[5] = &User{ID: 5, Name: "Partner", Email: "partner@other.com", Role: "Participant"}
+	d.members[9][4] = true
+	d.members[9][5] = true
+	client, server := testClientHandlerV2(d.ServeHTTP)
+	defer server.Close()
+
+	ds := NewDirectorySync(client, testDirectory)
+	ds.DeleteUsers = true
+	ds.ProtectedUserIDs = []int{4}
+	ds.Managed = func(u *User) bool { return strings.HasSuffix(u.Email, "@example.com") }
+	ds.MaxDeletePercent = 50
+	ds.SyncGroups = true
+	ds.CreateGroups = true
+	ds.RemoveMembers = true
+	ds.Concurrency = 1
+	report, err := ds.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"update user 1 alice.smith@example.com Alice 100 ",
+		"update user 2 bob@example.com Bob 0 Manager",
+		"create user 21 dave@example.com Dave Participant",
+		"create group 22 Ops",
+		"PUT group 22 user 21",
+		"PUT group 9 user 2",
+		"DELETE group 9 user 3",
+		"delete user 3",
+	}
+	if !reflect.DeepEqual(d.changes, expected) {
+		t.Errorf("Expected changes\n%q\ngot\n%q", expected, d.changes)
+	}
+	counts := map[string]int{}
+	for _, c := range report.Changes {
+		counts[c.Action]++
+	}
+	expectedCounts := map[string]int{
+		DirectoryUpdateUser:   2,
+		DirectoryCreateUser:   1,
+		DirectoryCreateGroup:  1,
+		DirectoryAddMember:    2,
+		DirectoryRemoveMember: 1,
+		DirectoryDeleteUser:   1,
+	}
+	if !reflect.DeepEqual(counts, expectedCounts) {
+		t.Errorf("Expected %v changes, got %v", expectedCounts, counts)
+	}
+	if report.Changes[0].Fields[0] != "email" || len(report.Failed()) != 0 || report.UnchangedUsers != 0 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if !d.members[9][4] || !d.members[9][5] {
+		t.Errorf("Expected the protected and unmanaged members to stay in Sales, got %v", d.members[9])
+	}
+
+	// a second run has nothing to do.
+	d.changes = nil
+	report, err = ds.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.changes) != 0 || len(report.Changes) != 0 || report.UnchangedUsers != 3 {
+		t.Errorf("Expected no changes, got %q %+v", d.changes, report)
+	}
+}
+
+func TestDirectorySync_RunLimit(t *testing.T) {
+	d := newDirectoryTestServer(t)
+	client, server := testClientHandlerV2(d.ServeHTTP)
+	defer server.Close()
+
+	ds := NewDirectorySync(client, testDirectory)
+	ds.DeleteUsers = true
+	report, err := ds.Run(context.Background())
+	limitErr, ok := err.(DirectorySyncLimitError)
+	if !ok || limitErr.Deletes != 2 || limitErr.Managed != 4 {
+		t.Fatalf("Expected a DirectorySyncLimitError, got %v", err)
+	}
+	if len(d.changes) != 0 {
+		t.Errorf("Expected no changes, got %q", d.changes)
+	}
+	if report.Count(DirectoryDeleteUser) != 2 || report.Count(DirectoryCreateUser) != 1 {
+		t.Errorf("Expected the report to list the planned changes, got %+v", report.Changes)
+	}
+
+	// 0 allows no deletes at all.
+	ds.ProtectedUserIDs = []int{4}
+	ds.MaxDeletePercent = 0
+	_, err = ds.Run(context.Background())
+	if limitErr, ok := err.(DirectorySyncLimitError); !ok || limitErr.Deletes != 1 || limitErr.Managed != 3 {
+		t.Errorf("Expected a DirectorySyncLimitError, got %v", err)
+	}
+	if len(d.changes) != 0 {
+		t.Errorf("Expected no changes, got %q", d.changes)
+	}
+}
+
+func TestDirectorySync_RunGroupLimit(t *testing.T) {
+	d := newDirectoryTestServer(t)
+	client, server := testClientHandlerV2(d.ServeHTTP)
+	defer server.Close()
+
+	ds := NewDirectorySync(client, testDirectory)
+	ds.SyncGroups = true
+	ds.CreateGroups = true
+	ds.RemoveMembers = true
+	report, err := ds.Run(context.Background())
+	limitErr, ok := err.(DirectorySyncLimitError)
+	if !ok || limitErr.Group != "Sales" || limitErr.Deletes != 1 || limitErr.Managed != 2 {
+		t.Fatalf("Expected a DirectorySyncLimitError for Sales, got %v", err)
+	}
+	if len(d.changes) != 0 {
+		t.Errorf("Expected no changes, got %q", d.changes)
+	}
+	if report.Count(DirectoryRemoveMember) != 1 {
+		t.Errorf("Expected the report to list the planned removal, got %+v", report.Changes)
+	}
+
+	// without RemoveMembers the directory only adds members.
+	ds.RemoveMembers = false
+	if _, err := ds.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !d.members[9][3] || !d.members[9][2] {
+		t.Errorf("Expected Bob to be added to Sales and Carol kept, got %v", d.members[9])
+	}
+}
+
+func TestDirectorySync_RunDryRun(t *testing.T) {
+	d := newDirectoryTestServer(t)
+	client, server := testClientHandlerV2(d.ServeHTTP)
+	defer server.Close()
+
+	directory := append(MemoryDirectory{{Name: "Nobody"}}, testDirectory...)
+	ds := NewDirectorySync(client, directory)
+	ds.DryRun = true
+	ds.SyncGroups = true
+	ds.RemoveMembers = true
+	ds.MaxDeletePercent = 50
+	report, err := ds.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.changes) != 0 {
+		t.Errorf("Expected a dry run to make no changes, got %q", d.changes)
+	}
+	if failed := report.Failed(); len(failed) != 2 || failed[0].Action != DirectoryCreateUser || failed[1].Group != "Ops" {
+		t.Errorf("Expected the invalid user and missing group to fail, got %+v", failed)
+	}
+	if report.Count(DirectoryAddMember) != 1 || report.Count(DirectoryRemoveMember) != 1 || report.Count(DirectoryDeleteUser) != 0 {
+		t.Errorf("Unexpected report %+v", report.Changes)
+	}
+}
+
+func TestCSVDirectory_Users(t *testing.T) {
+	dir, err := ioutil.TempDir("", "directory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "users.csv")
+	data := "email,employeeNumber,name,department,groups\n" +
+		"alice@example.com,100,Alice,Sales,Sales; Managers\n" +
+		" bob@example.com ,,Bob,Ops,\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := CSVDirectory{Path: path}.Users(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []DirectoryUser{
+		{Email: "alice@example.com", EmployeeNumber: 100, Name: "Alice", Groups: []string{"Sales", "Managers"}},
+		{Email: "bob@example.com", Name: "Bob"},
+	}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, users)
+	}
+}
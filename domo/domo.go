@@ -24,10 +24,11 @@ const (
 	userAgent      = "domo-gopher"
 	// DomoDateFormat can be used with time.Parse to create time.Time values
 	// from domo date strings.
-	DomoDateFormat = "2018-09-17"
+	DomoDateFormat = "2006-01-02"
 	// DomoTimestampFormat can be used with time.Parse to create time.Time
-	// values from domo timestamp strings. ISO 8601 UTC timestamp 0 offset
-	DomoTimestampFormat = "2018-09-17T15:04:05Z"
+	// values from domo timestamp strings. ISO 8601 UTC timestamp 0 offset.
+	// Use ParseTimestamp for timestamps in any of the formats Domo emits.
+	DomoTimestampFormat = "2006-01-02T15:04:05Z"
 )
 
 // Client is a client for working with the Domo API.
@@ -41,6 +42,11 @@ type Client struct {
 	BaseURL *url.URL
 	// User agent used when communicating with the Domo API.
 	UserAgent string
+	// CompressUploads gzips the CSV of every dataset and stream data part upload. The data is compressed as it's
+	// sent, so a compressed copy is never held in memory.
+	CompressUploads bool
+	// OnUpload, if set, is called after every dataset or stream data part upload with its byte counts.
+	OnUpload func(UploadStats)
 
 	common service // Reuse a single struct instead of allocating one for each service on the heap.
 
@@ -137,7 +143,7 @@ func (c *Client) Do(ctx context.Context, req *http.Request, v interface{}) (*htt
 	if ctx == nil {
 		return nil, errors.New("context must be non-nil")
 	}
-	req.WithContext(ctx)
+	req = req.WithContext(ctx)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
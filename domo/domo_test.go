@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"testing"
 )
 
 var (
@@ -50,3 +51,69 @@ func testClientFileV2(code int, filename string, validators ...func(*http.Reques
 	}
 	return testClientV2(code, f)
 }
+
+// Client whose reqs are all sent to handler. Use it for methods that make more than one request.
+func testClientHandlerV2(handler http.HandlerFunc) (*Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+
+	client := NewClient(nil)
+	u, _ := url.Parse(server.URL + "/")
+	client.BaseURL = u
+
+	return client, server
+}
+
+// testRoute serves the requests matching pattern, a method and path like "PUT /v1/streams/*/executions/*/commit"
+// where a "*" segment matches any one path segment. A nil handler responds 200 with no body.
+type testRoute struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// testRouter serves each request with the first of its routes matching it, failing the test on requests
+// no route matches.
+type testRouter struct {
+	t      *testing.T
+	routes []testRoute
+}
+
+func (rt testRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, route := range rt.routes {
+		if route.matches(r) {
+			if route.handler != nil {
+				route.handler(w, r)
+			}
+			return
+		}
+	}
+	rt.t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+}
+
+func (route testRoute) matches(r *http.Request) bool {
+	method, path, _ := strings.Cut(route.pattern, " ")
+	if method != r.Method {
+		return false
+	}
+	want, got := strings.Split(path, "/"), strings.Split(r.URL.Path, "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] != "*" && want[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Client whose reqs are served by routes. Use it for methods that make more than one request.
+func testClientRoutesV2(t *testing.T, routes ...testRoute) (*Client, *httptest.Server) {
+	return testClientHandlerV2(testRouter{t: t, routes: routes}.ServeHTTP)
+}
+
+// testBody is a route handler that always responds with body.
+func testBody(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}
+}
@@ -0,0 +1,262 @@
+package domo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Freshness statuses of a FreshnessResult.
+const (
+	// FreshnessOK is a dataset updated within its SLA whose last stream execution didn't fail.
+	FreshnessOK = "OK"
+	// FreshnessStale is a dataset not updated within its SLA.
+	FreshnessStale = "STALE"
+	// FreshnessFailed is a dataset whose stream's last execution errored or was aborted, or has been
+	// running for longer than the SLA.
+	FreshnessFailed = "FAILED"
+	// FreshnessUnknown is a dataset whose freshness couldn't be checked.
+	FreshnessUnknown = "UNKNOWN"
+)
+
+// FreshnessTarget is a dataset watched by a FreshnessMonitor, given by its DatasetID or by the StreamID
+// that loads it. Watching the stream also flags a last execution that errored, was aborted or is stuck.
+type FreshnessTarget struct {
+	DatasetID string `json:"datasetId,omitempty"`
+	StreamID  int    `json:"streamId,omitempty"`
+	// SLA is the longest the dataset may go without an update. Zero only checks the last execution.
+	SLA time.Duration `json:"sla"`
+}
+
+func (t FreshnessTarget) String() string {
+	if t.StreamID != 0 {
+		return fmt.Sprintf("stream %d", t.StreamID)
+	}
+	return fmt.Sprintf("dataset %s", t.DatasetID)
+}
+
+// FreshnessResult is the freshness of a FreshnessTarget.
+type FreshnessResult struct {
+	Target    FreshnessTarget `json:"target"`
+	DatasetID string          `json:"datasetId,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Status    string          `json:"status"`
+	// UpdatedAt is the dataset's DataCurrentAt, or UpdatedAt if that's not set.
+	UpdatedAt     time.Time        `json:"updatedAt,omitempty"`
+	Age           time.Duration    `json:"age,omitempty"`
+	LastExecution *StreamExecution `json:"lastExecution,omitempty"`
+	// Error is why the status is FreshnessUnknown.
+	Error string `json:"error,omitempty"`
+}
+
+// FreshnessReport is the result of a FreshnessMonitor check.
+type FreshnessReport struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	// Healthy is true when every target is FreshnessOK.
+	Healthy bool              `json:"healthy"`
+	Results []FreshnessResult `json:"results"`
+}
+
+// FreshnessMonitor watches datasets and streams, flagging those that are stale relative to their SLA or
+// whose last stream execution errored. Use Check for a single pass or Run to check on a ticker. The
+// monitor is an http.Handler serving its latest report.
+type FreshnessMonitor struct {
+	Client  *Client
+	Targets []FreshnessTarget
+	// OnChange, if set, is called with each result whose status differs from the previous check's, so
+	// a stale dataset is reported once and again when it recovers. Targets that are OK on the first check
+	// aren't reported.
+	OnChange func(ctx context.Context, result FreshnessResult) error
+	// OnError, if set, is called with errors returned by OnChange.
+	OnError func(result FreshnessResult, err error)
+
+	mu       sync.RWMutex
+	latest   *FreshnessReport
+	statuses map[FreshnessTarget]string
+	now      func() time.Time
+}
+
+// NewFreshnessMonitor returns a FreshnessMonitor watching the targets.
+func NewFreshnessMonitor(client *Client, targets ...FreshnessTarget) *FreshnessMonitor {
+	return &FreshnessMonitor{Client: client, Targets: targets}
+}
+
+// Check every target once, notify OnChange of status changes and keep the report for ServeHTTP. A
+// target that can't be checked is reported as FreshnessUnknown instead of stopping the check.
+func (m *FreshnessMonitor) Check(ctx context.Context) *FreshnessReport {
+	now := time.Now
+	if m.now != nil {
+		now = m.now
+	}
+	report := &FreshnessReport{CheckedAt: now().UTC(), Healthy: true}
+	for _, target := range m.Targets {
+		result := m.check(ctx, target, report.CheckedAt)
+		report.Healthy = report.Healthy && result.Status == FreshnessOK
+		report.Results = append(report.Results, result)
+	}
+
+	m.mu.Lock()
+	previous := m.statuses
+	m.statuses = make(map[FreshnessTarget]string, len(report.Results))
+	for _, result := range report.Results {
+		m.statuses[result.Target] = result.Status
+	}
+	m.latest = report
+	m.mu.Unlock()
+
+	if m.OnChange == nil {
+		return report
+	}
+	for _, result := range report.Results {
+		status, seen := previous[result.Target]
+		if status == result.Status || (!seen && result.Status == FreshnessOK) {
+			continue
+		}
+		if err := m.OnChange(ctx, result); err != nil && m.OnError != nil {
+			m.OnError(result, err)
+		}
+	}
+	return report
+}
+
+func (m *FreshnessMonitor) check(ctx context.Context, target FreshnessTarget, now time.Time) FreshnessResult {
+	result := FreshnessResult{Target: target, DatasetID: target.DatasetID}
+	unknown := func(err error) FreshnessResult {
+		result.Status, result.Error = FreshnessUnknown, err.Error()
+		return result
+	}
+
+	var ds *Dataset
+	if target.StreamID != 0 {
+		stream, _, err := m.Client.Streams.Info(ctx, target.StreamID)
+		if err != nil {
+			return unknown(err)
+		}
+		result.LastExecution = stream.LastExecution
+		ds = stream.Dataset
+	}
+	if ds == nil || (ds.DataCurrentAt == nil && ds.UpdatedAt == nil) {
+		// the stream's dataset summary may not have the update times.
+		id := result.DatasetID
+		if ds != nil {
+			id = ds.ID
+		}
+		if id == "" {
+			return unknown(fmt.Errorf("%s has no dataset", target))
+		}
+		var err error
+		if ds, _, err = m.Client.Datasets.Info(ctx, id); err != nil {
+			return unknown(err)
+		}
+	}
+	result.DatasetID, result.Name = ds.ID, ds.Name
+
+	updatedAt, ok := firstTimestamp(ds.DataCurrentAt, ds.UpdatedAt)
+	if !ok {
+		return unknown(fmt.Errorf("dataset %s has no update time", ds.ID))
+	}
+	result.UpdatedAt, result.Age = updatedAt, now.Sub(updatedAt)
+
+	switch {
+	case executionFailed(result.LastExecution, target.SLA, now):
+		result.Status = FreshnessFailed
+	case target.SLA > 0 && result.Age > target.SLA:
+		result.Status = FreshnessStale
+	default:
+		result.Status = FreshnessOK
+	}
+	return result
+}
+
+// executionFailed reports whether a stream's last execution errored, was aborted or has been active for
+// longer than the SLA.
+func executionFailed(e *StreamExecution, sla time.Duration, now time.Time) bool {
+	if e == nil {
+		return false
+	}
+	switch e.CurrentState {
+	case ExecutionStateError, ExecutionStateAborted:
+		return true
+	case ExecutionStateActive:
+		startedAt, ok := firstTimestamp(e.StartedAt, e.CreatedAt)
+		return ok && sla > 0 && now.Sub(startedAt) > sla
+	}
+	return false
+}
+
+// Run checks every interval until ctx is done, passing each report to report if it's set. It returns ctx.Err().
+func (m *FreshnessMonitor) Run(ctx context.Context, interval time.Duration, report func(*FreshnessReport)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// a tick can be ready alongside ctx.Done, and checking with a cancelled context would report
+		// every target as unknown.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r := m.Check(ctx)
+		if report != nil {
+			report(r)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Latest returns the report of the last check, or nil before the first.
+func (m *FreshnessMonitor) Latest() *FreshnessReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.latest
+}
+
+// ServeHTTP writes the latest report as JSON, with a 503 status when a target isn't FreshnessOK or
+// nothing has been checked yet, so the monitor can back a status endpoint or health check.
+func (m *FreshnessMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := m.Latest()
+	w.Header().Set("Content-Type", "application/json")
+	if report == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"healthy": false, "results": []}`))
+		return
+	}
+	if !report.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// FreshnessWebhook returns an OnChange func posting each result as JSON to url. A nil httpClient uses
+// http.DefaultClient.
+func FreshnessWebhook(httpClient *http.Client, url string) func(ctx context.Context, result FreshnessResult) error {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return func(ctx context.Context, result FreshnessResult) error {
+		body, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := httpClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("freshness webhook %s returned %s", url, resp.Status)
+		}
+		return nil
+	}
+}
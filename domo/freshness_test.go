@@ -0,0 +1,178 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFreshnessMonitor_Check(t *testing.T) {
+	state, startedAt := ExecutionStateSuccess, "2019-06-02T07:00:00Z"
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/datasets/fresh":
+			json.NewEncoder(w).Encode(Dataset{ID: "fresh", Name: "Fresh", DataCurrentAt: mustTimestamp("2019-06-02T09:00:00Z")})
+		case "/v1/datasets/stale":
+			json.NewEncoder(w).Encode(Dataset{ID: "stale", Name: "Stale", UpdatedAt: mustTimestamp("2019-05-30T10:00:00Z")})
+		case "/v1/datasets/streamed":
+			json.NewEncoder(w).Encode(Dataset{ID: "streamed", Name: "Streamed", DataCurrentAt: mustTimestamp("2019-06-02T08:00:00Z")})
+		case "/v1/streams/42":
+			json.NewEncoder(w).Encode(StreamDataset{
+				ID:            42,
+				Dataset:       &Dataset{ID: "streamed"},
+				LastExecution: &StreamExecution{ID: 7, CurrentState: state, StartedAt: mustTimestamp(startedAt)},
+			})
+		default:
+			http.Error(w, `{"error": {"status": 404, "message": "not found"}}`, http.StatusNotFound)
+		}
+	})
+	defer server.Close()
+
+	var changes []FreshnessResult
+	m := NewFreshnessMonitor(client,
+		FreshnessTarget{DatasetID: "fresh", SLA: 2 * time.Hour},
+		FreshnessTarget{DatasetID: "stale", SLA: 24 * time.Hour},
+		FreshnessTarget{StreamID: 42, SLA: 24 * time.Hour},
+		FreshnessTarget{DatasetID: "missing", SLA: time.Hour},
+	)
+	m.now = func() time.Time { return time.Date(2019, 6, 2, 10, 0, 0, 0, time.UTC) }
+	m.OnChange = func(ctx context.Context, result FreshnessResult) error {
+		changes = append(changes, result)
+		return nil
+	}
+
+	report := m.Check(context.Background())
+	if report.Healthy || len(report.Results) != 4 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	statuses := []string{FreshnessOK, FreshnessStale, FreshnessOK, FreshnessUnknown}
+	for i, result := range report.Results {
+		if result.Status != statuses[i] {
+			t.Errorf("Expected %s to be %s, got %+v", result.Target, statuses[i], result)
+		}
+	}
+	if stale := report.Results[1]; stale.Age != 72*time.Hour || stale.Name != "Stale" {
+		t.Errorf("Unexpected stale result %+v", stale)
+	}
+	if streamed := report.Results[2]; streamed.DatasetID != "streamed" || streamed.LastExecution.ID != 7 {
+		t.Errorf("Unexpected stream result %+v", streamed)
+	}
+	if len(changes) != 2 || changes[0].Target.DatasetID != "stale" || changes[1].Target.DatasetID != "missing" {
+		t.Errorf("Expected the stale and unknown targets to be reported, got %+v", changes)
+	}
+
+	// only the stream's change is reported on the next check.
+	changes = nil
+	state = ExecutionStateError
+	report = m.Check(context.Background())
+	if len(changes) != 1 || changes[0].Status != FreshnessFailed || changes[0].Target.StreamID != 42 {
+		t.Errorf("Expected the failed stream to be reported, got %+v", changes)
+	}
+	if m.Latest() != report {
+		t.Error("Expected the latest report to be kept")
+	}
+
+	executions := []struct {
+		state     string
+		startedAt string
+		status    string
+	}{
+		{ExecutionStateAborted, "2019-06-02T07:00:00Z", FreshnessFailed},
+		{ExecutionStateActive, "2019-06-02T07:00:00Z", FreshnessOK},
+		{ExecutionStateActive, "2019-05-31T07:00:00Z", FreshnessFailed},
+	}
+	for _, e := range executions {
+		state, startedAt = e.state, e.startedAt
+		report = m.Check(context.Background())
+		if status := report.Results[2].Status; status != e.status {
+			t.Errorf("Expected a stream whose last execution is %s since %s to be %s, got %s", e.state, e.startedAt, e.status, status)
+		}
+	}
+}
+
+func TestFreshnessMonitor_ServeHTTP(t *testing.T) {
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Dataset{ID: "fresh", DataCurrentAt: mustTimestamp("2019-06-02T09:00:00Z")})
+	})
+	defer server.Close()
+
+	m := NewFreshnessMonitor(client, FreshnessTarget{DatasetID: "fresh", SLA: 2 * time.Hour})
+	m.now = func() time.Time { return time.Date(2019, 6, 2, 10, 0, 0, 0, time.UTC) }
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before the first check, got %d", rec.Code)
+	}
+
+	m.Check(context.Background())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	var report FreshnessReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || !report.Healthy || report.Results[0].Status != FreshnessOK {
+		t.Errorf("Unexpected status %d %+v", rec.Code, report)
+	}
+}
+
+func TestFreshnessMonitor_RunCancel(t *testing.T) {
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Dataset{ID: "fresh", DataCurrentAt: mustTimestamp("2019-06-02T09:00:00Z")})
+	})
+	defer server.Close()
+
+	m := NewFreshnessMonitor(client, FreshnessTarget{DatasetID: "fresh", SLA: 2 * time.Hour})
+	m.now = func() time.Time { return time.Date(2019, 6, 2, 10, 0, 0, 0, time.UTC) }
+	changes := 0
+	m.OnChange = func(ctx context.Context, result FreshnessResult) error {
+		changes++
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	checks := 0
+	err := m.Run(ctx, time.Millisecond, func(r *FreshnessReport) {
+		checks++
+		cancel()
+		// let a tick be pending alongside the cancellation.
+		time.Sleep(5 * time.Millisecond)
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected Run to return the context error, got %v", err)
+	}
+	if checks != 1 || changes != 0 {
+		t.Errorf("Expected a single check and no changes, got %d checks and %d changes", checks, changes)
+	}
+	if latest := m.Latest(); latest == nil || !latest.Healthy {
+		t.Errorf("Expected the healthy report to be kept, got %+v", latest)
+	}
+}
+
+func TestFreshnessWebhook(t *testing.T) {
+	var got FreshnessResult
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer hook.Close()
+
+	notify := FreshnessWebhook(nil, hook.URL)
+	result := FreshnessResult{Target: FreshnessTarget{DatasetID: "abc"}, DatasetID: "abc", Status: FreshnessStale}
+	if err := notify(context.Background(), result); err != nil {
+		t.Fatal(err)
+	}
+	if got.DatasetID != "abc" || got.Status != FreshnessStale {
+		t.Errorf("Unexpected webhook body %+v", got)
+	}
+
+	hook.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	if err := notify(context.Background(), result); err == nil {
+		t.Error("Expected an error for a failed webhook")
+	}
+}
@@ -194,3 +194,10 @@ func (s *GroupsService) UserIDs(ctx context.Context, groupID, limit, offset int)
 
 	return userIDs, resp, nil
 }
+
+// AllUserIDs pages through UserIDs and returns every user id in the group.
+func (s *GroupsService) AllUserIDs(ctx context.Context, groupID int) ([]int, error) {
+	return allPaged(ctx, func(ctx context.Context, limit, offset int) ([]int, *http.Response, error) {
+		return s.UserIDs(ctx, groupID, limit, offset)
+	})
+}
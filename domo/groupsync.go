@@ -0,0 +1,151 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultSyncConcurrency is the number of membership changes SyncMembers makes at once.
+const DefaultSyncConcurrency = 4
+
+// SyncMembersOptions configures GroupsService.SyncMembers.
+type SyncMembersOptions struct {
+	// Concurrency is the number of users added or removed at once. Defaults to DefaultSyncConcurrency.
+	Concurrency int
+	// DryRun reports the changes without making them.
+	DryRun bool
+}
+
+// MemberSyncFailure is a user SyncMembers failed to add to or remove from a group.
+type MemberSyncFailure struct {
+	UserID int
+	// Removing is true when the user was being removed from the group, false when being added.
+	Removing bool
+	Err      error
+}
+
+func (f MemberSyncFailure) Error() string {
+	if f.Removing {
+		return fmt.Sprintf("removing user %d: %v", f.UserID, f.Err)
+	}
+	return fmt.Sprintf("adding user %d: %v", f.UserID, f.Err)
+}
+
+// SyncMembersReport describes the membership changes made by SyncMembers. On a dry run Added and Removed
+// are the changes that would be made.
+type SyncMembersReport struct {
+	GroupID int
+	DryRun  bool
+	Added   []int
+	Removed []int
+	Failed  []MemberSyncFailure
+	// Unchanged is the number of desired users already in the group.
+	Unchanged int
+}
+
+// SyncMembers makes the group's members exactly the desired users, adding the missing ones and removing
+// the rest. A user that fails to be added or removed is recorded in the report's Failed instead of
+// stopping the sync. The error is only set when the group's members can't be listed.
+func (s *GroupsService) SyncMembers(ctx context.Context, groupID int, desiredUserIDs []int, opts SyncMembersOptions) (*SyncMembersReport, error) {
+	current, err := s.AllUserIDs(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[int]bool, len(current))
+	for _, id := range current {
+		members[id] = true
+	}
+	desired := make(map[int]bool, len(desiredUserIDs))
+	for _, id := range desiredUserIDs {
+		desired[id] = true
+	}
+
+	report := &SyncMembersReport{GroupID: groupID, DryRun: opts.DryRun}
+	var add, remove []int
+	for id := range desired {
+		if members[id] {
+			report.Unchanged++
+		} else {
+			add = append(add, id)
+		}
+	}
+	for id := range members {
+		if !desired[id] {
+			remove = append(remove, id)
+		}
+	}
+	sort.Ints(add)
+	sort.Ints(remove)
+	if opts.DryRun {
+		report.Added, report.Removed = add, remove
+		return report, nil
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultSyncConcurrency
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	apply := func(userID int, removing bool) {
+		var err error
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err == nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var err error
+				if removing {
+					_, err = s.RemoveUser(ctx, groupID, userID)
+				} else {
+					_, err = s.AddUser(ctx, groupID, userID)
+				}
+				<-sem
+				mu.Lock()
+				defer mu.Unlock()
+				report.record(userID, removing, err)
+			}()
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		report.record(userID, removing, err)
+	}
+	for _, id := range add {
+		apply(id, false)
+	}
+	for _, id := range remove {
+		apply(id, true)
+	}
+	wg.Wait()
+
+	sort.Ints(report.Added)
+	sort.Ints(report.Removed)
+	sort.Slice(report.Failed, func(i, j int) bool {
+		if report.Failed[i].Removing != report.Failed[j].Removing {
+			return !report.Failed[i].Removing
+		}
+		return report.Failed[i].UserID < report.Failed[j].UserID
+	})
+	return report, nil
+}
+
+func (r *SyncMembersReport) record(userID int, removing bool, err error) {
+	switch {
+	case err != nil:
+		r.Failed = append(r.Failed, MemberSyncFailure{UserID: userID, Removing: removing, Err: err})
+	case removing:
+		r.Removed = append(r.Removed, userID)
+	default:
+		r.Added = append(r.Added, userID)
+	}
+}
@@ -0,0 +1,110 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// groupSyncTestServer serves a group's members, failing changes to the users in fail.
+type groupSyncTestServer struct {
+	t       *testing.T
+	mu      sync.Mutex
+	members map[int]bool
+	fail    map[int]bool
+	active  int
+	peak    int
+}
+
+func (g *groupSyncTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" && r.URL.Path == "/v1/groups/9/users" {
+		g.mu.Lock()
+		ids := []int{}
+		for id := range g.members {
+			ids = append(ids, id)
+		}
+		g.mu.Unlock()
+		json.NewEncoder(w).Encode(ids)
+		return
+	}
+	var userID int
+	if _, err := fmt.Sscanf(r.URL.Path, "/v1/groups/9/users/%d", &userID); err != nil {
+		g.t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		return
+	}
+
+	g.mu.Lock()
+	g.active++
+	if g.active > g.peak {
+		g.peak = g.active
+	}
+	g.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active--
+
+	if g.fail[userID] {
+		http.Error(w, `{"error": {"status": 400, "message": "nope"}}`, http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case "PUT":
+		g.members[userID] = true
+	case "DELETE":
+		delete(g.members, userID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestGroupsService_SyncMembers(t *testing.T) {
+	g := &groupSyncTestServer{
+		t:       t,
+		members: map[int]bool{1: true, 2: true, 3: true, 4: true},
+		fail:    map[int]bool{4: true, 8: true},
+	}
+	client, server := testClientHandlerV2(g.ServeHTTP)
+	defer server.Close()
+
+	desired := []int{2, 3, 5, 6, 7, 8, 9, 10}
+	report, err := client.Groups.SyncMembers(context.Background(), 9, desired, SyncMembersOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Added, []int{5, 6, 7, 9, 10}) || !reflect.DeepEqual(report.Removed, []int{1}) || report.Unchanged != 2 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(report.Failed) != 2 || report.Failed[0].UserID != 8 || report.Failed[0].Removing ||
+		report.Failed[1].UserID != 4 || !report.Failed[1].Removing {
+		t.Errorf("Unexpected failures %+v", report.Failed)
+	}
+	if g.peak > 2 {
+		t.Errorf("Expected at most 2 concurrent changes, got %d", g.peak)
+	}
+	expected := map[int]bool{2: true, 3: true, 4: true, 5: true, 6: true, 7: true, 9: true, 10: true}
+	if !reflect.DeepEqual(g.members, expected) {
+		t.Errorf("Expected members %v, got %v", expected, g.members)
+	}
+}
+
+func TestGroupsService_SyncMembersDryRun(t *testing.T) {
+	g := &groupSyncTestServer{t: t, members: map[int]bool{1: true, 2: true}}
+	client, server := testClientHandlerV2(g.ServeHTTP)
+	defer server.Close()
+
+	report, err := client.Groups.SyncMembers(context.Background(), 9, []int{2, 3}, SyncMembersOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || !reflect.DeepEqual(report.Added, []int{3}) || !reflect.DeepEqual(report.Removed, []int{1}) {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if !reflect.DeepEqual(g.members, map[int]bool{1: true, 2: true}) {
+		t.Errorf("Expected a dry run not to change members, got %v", g.members)
+	}
+}
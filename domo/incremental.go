@@ -0,0 +1,278 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CheckpointStore persists incremental load watermarks between runs. Implement it to keep
+// watermarks in your own database, or use FileCheckpointStore.
+type CheckpointStore interface {
+	// Load the watermark saved for name. ok is false if nothing has been saved yet.
+	Load(ctx context.Context, name string) (watermark string, ok bool, err error)
+	// Save the watermark for name.
+	Save(ctx context.Context, name, watermark string) error
+}
+
+// FileCheckpointStore is a CheckpointStore keeping every watermark in a single local JSON file.
+type FileCheckpointStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileCheckpointStore returns a CheckpointStore backed by the JSON file at path. The file is
+// created on the first Save.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{Path: path}
+}
+
+// Load the watermark saved for name.
+func (s *FileCheckpointStore) Load(ctx context.Context, name string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checkpoints, err := s.read()
+	if err != nil {
+		return "", false, err
+	}
+	w, ok := checkpoints[name]
+	return w, ok, nil
+}
+
+// Save the watermark for name. The file is replaced atomically so a crash mid-write can't lose
+// the other watermarks.
+func (s *FileCheckpointStore) Save(ctx context.Context, name, watermark string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checkpoints, err := s.read()
+	if err != nil {
+		return err
+	}
+	checkpoints[name] = watermark
+	b, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, b)
+}
+
+func (s *FileCheckpointStore) read() (map[string]string, error) {
+	checkpoints := make(map[string]string)
+	b, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &checkpoints); err != nil {
+		return nil, fmt.Errorf("reading checkpoints %s: %v", s.Path, err)
+	}
+	return checkpoints, nil
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// IncrementalLoader appends only new rows to a stream on each run, tracking the high watermark
+// of WatermarkColumn (i.e. updated_at) in a CheckpointStore.
+//
+// Watermarks are stored the way the column is written to CSV, so a LONG watermark looks like 42,
+// except times which keep their full precision in UTC, i.e. 2019-03-04T15:04:05.123456789Z.
+type IncrementalLoader[T any] struct {
+	Stream *TypedStream[T]
+	Store  CheckpointStore
+	// Name the watermark is saved under. Defaults to the stream ID.
+	Name string
+	// WatermarkColumn is the name of the column tracked as the high watermark.
+	WatermarkColumn string
+	// Source returns the rows added or changed after the since watermark. since is empty on the first run.
+	Source func(ctx context.Context, since string) ([]T, error)
+}
+
+// IncrementalResult describes a single IncrementalLoader run.
+type IncrementalResult struct {
+	// Rows appended to the stream.
+	Rows int
+	// Previous watermark the source was asked for rows after.
+	Previous string
+	// Watermark saved after the run. It's unchanged when there were no new rows.
+	Watermark string
+	// Execution that appended the rows, nil when there were no new rows.
+	Execution *StreamExecution
+}
+
+// Run fetches the rows after the saved watermark from Source, appends them to the stream through a
+// stream execution and saves the new watermark. The watermark is only saved once the execution has been
+// committed, so a failed run is retried from the same watermark on the next run.
+func (l *IncrementalLoader[T]) Run(ctx context.Context) (*IncrementalResult, error) {
+	if l.Source == nil {
+		return nil, fmt.Errorf("incremental loader has no Source")
+	}
+	si, err := getStructInfo(typeOf[T]())
+	if err != nil {
+		return nil, err
+	}
+	var watermarkField *fieldInfo
+	for i := range si.Fields {
+		if si.Fields[i].matchesKey(l.WatermarkColumn) {
+			watermarkField = &si.Fields[i]
+			break
+		}
+	}
+	if watermarkField == nil {
+		return nil, fmt.Errorf("%s has no watermark column %q", typeOf[T](), l.WatermarkColumn)
+	}
+	name := l.Name
+	if name == "" {
+		name = strconv.Itoa(l.Stream.ID)
+	}
+
+	previous, _, err := l.Store.Load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	result := &IncrementalResult{Previous: previous, Watermark: previous}
+	rows, err := l.Source(ctx, previous)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	watermark := previous
+	for i, row := range rows {
+		cell, err := watermarkCell(watermarkField, reflect.Indirect(reflect.ValueOf(row)))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", i, err)
+		}
+		if cell == "" {
+			continue
+		}
+		if watermark == "" {
+			watermark = cell
+			continue
+		}
+		c, err := compareCells(watermarkField.DomoColumnType, cell, watermark)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", i, err)
+		}
+		if c > 0 {
+			watermark = cell
+		}
+	}
+
+	execution, err := l.Stream.Append(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = len(rows)
+	result.Execution = execution
+	if err := l.Store.Save(ctx, name, watermark); err != nil {
+		return result, err
+	}
+	result.Watermark = watermark
+	return result, nil
+}
+
+// watermarkCell formats the row's watermark field as a CSV cell, keeping the full precision of times.
+func watermarkCell(f *fieldInfo, row reflect.Value) (string, error) {
+	fv, ok := f.fieldValue(row)
+	for ok && (fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface) {
+		if fv.IsNil() {
+			return "", nil
+		}
+		fv = fv.Elem()
+	}
+	if ok && fv.Type() == timeType {
+		t := fv.Interface().(time.Time)
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+	return f.formatCell(row)
+}
+
+// compareCells compares two CSV cells of a Domo column type, returning -1, 0 or 1.
+func compareCells(columnType, a, b string) (int, error) {
+	switch columnType {
+	case ColumnTypeLong, ColumnTypeDouble, ColumnTypeDecimal:
+		// LONGs are compared as integers so they keep their precision above 2^53.
+		if columnType == ColumnTypeLong {
+			ia, errA := strconv.ParseInt(a, 10, 64)
+			ib, errB := strconv.ParseInt(b, 10, 64)
+			if errA == nil && errB == nil {
+				switch {
+				case ia < ib:
+					return -1, nil
+				case ia > ib:
+					return 1, nil
+				}
+				return 0, nil
+			}
+		}
+		fa, err := strconv.ParseFloat(a, 64)
+		if err != nil {
+			return 0, err
+		}
+		fb, err := strconv.ParseFloat(b, 64)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case fa < fb:
+			return -1, nil
+		case fa > fb:
+			return 1, nil
+		}
+		return 0, nil
+	case ColumnTypeDate, ColumnTypeDatetime:
+		ta, err := parseCSVTime(a)
+		if err != nil {
+			return 0, err
+		}
+		tb, err := parseCSVTime(b)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, nil
+		case ta.After(tb):
+			return 1, nil
+		}
+		return 0, nil
+	}
+	switch {
+	case a < b:
+		return -1, nil
+	case a > b:
+		return 1, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,163 @@
+package domo
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type eventRow struct {
+	ID        int       `domo:"id"`
+	UpdatedAt time.Time `domo:"updated_at"`
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+
+	if _, ok, err := store.Load(ctx, "a"); err != nil || ok {
+		t.Fatalf("Expected no watermark before the first save, got ok=%t err=%v", ok, err)
+	}
+	if err := store.Save(ctx, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, "b", "2"); err != nil {
+		t.Fatal(err)
+	}
+	reopened := NewFileCheckpointStore(store.Path)
+	w, ok, err := reopened.Load(ctx, "a")
+	if err != nil || !ok || w != "1" {
+		t.Errorf("Expected watermark 1, got %q ok=%t err=%v", w, ok, err)
+	}
+}
+
+func incrementalTestClient(t *testing.T, commitStatus int) (*Client, func()) {
+	client, server := testClientRoutesV2(t,
+		testRoute{"GET /v1/streams/42", testBody(`{"id": 42, "updateMethod": "REPLACE"}`)},
+		testRoute{"PATCH /v1/streams/42", testBody(`{"id": 42, "updateMethod": "APPEND"}`)},
+		testRoute{"POST /v1/streams/42/executions", testBody(`{"id": 1}`)},
+		testRoute{"PUT /v1/streams/42/executions/*/part/*", testBody(`{"id": 1}`)},
+		testRoute{"PUT /v1/streams/42/executions/*/commit", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(commitStatus)
+			if commitStatus == http.StatusOK {
+				w.Write([]byte(`{"id": 1, "currentState": "SUCCESS"}`))
+			} else {
+				w.Write([]byte(`{"error": {"status": 500, "message": "domo err msg"}}`))
+			}
+		}},
+		testRoute{"PUT /v1/streams/42/executions/*/abort", nil},
+	)
+	return client, server.Close
+}
+
+func TestIncrementalLoader_Run(t *testing.T) {
+	client, closeServer := incrementalTestClient(t, http.StatusOK)
+	defer closeServer()
+	ctx := context.Background()
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	store.Save(ctx, "events", "2019-03-01T00:00:00Z")
+
+	var asked string
+	loader := &IncrementalLoader[eventRow]{
+		Stream:          NewTypedStream[eventRow](client, 42),
+		Store:           store,
+		Name:            "events",
+		WatermarkColumn: "updated_at",
+		Source: func(ctx context.Context, since string) ([]eventRow, error) {
+			asked = since
+			return []eventRow{
+				{ID: 1, UpdatedAt: time.Date(2019, 3, 3, 0, 0, 0, 0, time.UTC)},
+				{ID: 2, UpdatedAt: time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC)},
+			}, nil
+		},
+	}
+	result, err := loader.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asked != "2019-03-01T00:00:00Z" {
+		t.Errorf("Expected the source to be asked for rows after the saved watermark, got %q", asked)
+	}
+	if result.Rows != 2 || result.Watermark != "2019-03-03T00:00:00Z" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if w, _, _ := store.Load(ctx, "events"); w != "2019-03-03T00:00:00Z" {
+		t.Errorf("Expected the new watermark to be saved, got %q", w)
+	}
+}
+
+func TestIncrementalLoader_RunSubsecondWatermark(t *testing.T) {
+	client, closeServer := incrementalTestClient(t, http.StatusOK)
+	defer closeServer()
+	ctx := context.Background()
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+
+	loader := &IncrementalLoader[eventRow]{
+		Stream:          NewTypedStream[eventRow](client, 42),
+		Store:           store,
+		Name:            "events",
+		WatermarkColumn: "updated_at",
+		Source: func(ctx context.Context, since string) ([]eventRow, error) {
+			return []eventRow{
+				{ID: 1, UpdatedAt: time.Date(2019, 3, 3, 12, 0, 0, 250000000, time.UTC)},
+				{ID: 2, UpdatedAt: time.Date(2019, 3, 3, 12, 0, 0, 500000000, time.UTC)},
+			}, nil
+		},
+	}
+	result, err := loader.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Watermark != "2019-03-03T12:00:00.5Z" {
+		t.Errorf("Expected the watermark to keep its fraction of a second, got %q", result.Watermark)
+	}
+}
+
+func TestCompareCells_Long(t *testing.T) {
+	// 2^53+1 and 2^53 are the same float64.
+	if c, err := compareCells(ColumnTypeLong, "9007199254740993", "9007199254740992"); err != nil || c != 1 {
+		t.Errorf("Expected 9007199254740993 > 9007199254740992, got %d %v", c, err)
+	}
+	if c, err := compareCells(ColumnTypeLong, "-5", "3"); err != nil || c != -1 {
+		t.Errorf("Expected -5 < 3, got %d %v", c, err)
+	}
+}
+
+func TestIncrementalLoader_RunCommitFails(t *testing.T) {
+	client, closeServer := incrementalTestClient(t, http.StatusInternalServerError)
+	defer closeServer()
+	ctx := context.Background()
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	store.Save(ctx, "events", "2019-03-01T00:00:00Z")
+
+	loader := &IncrementalLoader[eventRow]{
+		Stream:          NewTypedStream[eventRow](client, 42),
+		Store:           store,
+		Name:            "events",
+		WatermarkColumn: "updated_at",
+		Source: func(ctx context.Context, since string) ([]eventRow, error) {
+			return []eventRow{{ID: 1, UpdatedAt: time.Date(2019, 3, 3, 0, 0, 0, 0, time.UTC)}}, nil
+		},
+	}
+	if _, err := loader.Run(ctx); err == nil {
+		t.Fatal("Expected the failed commit to be returned")
+	}
+	if w, _, _ := store.Load(ctx, "events"); w != "2019-03-01T00:00:00Z" {
+		t.Errorf("Expected the watermark to be unchanged, got %q", w)
+	}
+}
+
+func TestIncrementalLoader_UnknownColumn(t *testing.T) {
+	loader := &IncrementalLoader[eventRow]{
+		Stream:          NewTypedStream[eventRow](nil, 42),
+		WatermarkColumn: "nope",
+		Source: func(ctx context.Context, since string) ([]eventRow, error) {
+			return nil, nil
+		},
+	}
+	if _, err := loader.Run(context.Background()); err == nil {
+		t.Error("Expected an error for a watermark column that doesn't exist")
+	}
+}
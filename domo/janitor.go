@@ -0,0 +1,160 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Janitor finds stream executions left ACTIVE by failed jobs and aborts them. Use Sweep for a single
+// pass or Run to sweep on a ticker.
+type Janitor struct {
+	Streams *StreamsService
+	// MaxAge an execution can be open before it's considered stale.
+	MaxAge time.Duration
+	// DryRun reports stale executions without aborting them.
+	DryRun bool
+	// AllowList limits the janitor to these stream ids. When empty every stream is scanned.
+	AllowList []int
+
+	now func() time.Time
+}
+
+// NewJanitor returns a Janitor aborting executions open for longer than maxAge.
+func NewJanitor(client *Client, maxAge time.Duration) *Janitor {
+	return &Janitor{Streams: client.Streams, MaxAge: maxAge}
+}
+
+// JanitorReport describes what a Janitor sweep found and did.
+type JanitorReport struct {
+	// StreamsScanned is the number of streams whose executions were checked.
+	StreamsScanned int
+	// Stale executions found. Aborted is false for all of them on a dry run.
+	Stale []StaleExecution
+	// Failed streams whose executions couldn't be listed.
+	Failed []JanitorStreamError
+}
+
+// JanitorStreamError is a stream a Janitor sweep couldn't list the executions of.
+type JanitorStreamError struct {
+	StreamID int
+	Err      error
+}
+
+func (e JanitorStreamError) Error() string {
+	return fmt.Sprintf("listing executions of stream %d: %v", e.StreamID, e.Err)
+}
+
+// StaleExecution is an execution that has been open longer than the Janitor's MaxAge.
+type StaleExecution struct {
+	StreamID  int
+	Execution *StreamExecution
+	Age       time.Duration
+	Aborted   bool
+	// Err is set if aborting the execution failed.
+	Err error
+}
+
+// Sweep scans the streams once and aborts, or on a dry run only reports, every stale execution. An
+// error listing a stream's executions or aborting a single execution is recorded in the report instead
+// of stopping the sweep.
+func (j *Janitor) Sweep(ctx context.Context) (*JanitorReport, error) {
+	if j.MaxAge <= 0 {
+		return nil, fmt.Errorf("janitor MaxAge must be above 0, but %s is not", j.MaxAge)
+	}
+	streamIDs := j.AllowList
+	if len(streamIDs) == 0 {
+		var err error
+		streamIDs, err = j.allStreamIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now
+	if j.now != nil {
+		now = j.now
+	}
+
+	report := &JanitorReport{}
+	for _, streamID := range streamIDs {
+		executions, err := j.activeExecutions(ctx, streamID)
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if err != nil {
+			report.Failed = append(report.Failed, JanitorStreamError{StreamID: streamID, Err: err})
+			continue
+		}
+		report.StreamsScanned++
+		for _, e := range executions {
+			startedAt, ok := firstTimestamp(e.StartedAt, e.CreatedAt)
+			if !ok {
+				err := fmt.Errorf("stream %d execution %d has no start time", streamID, e.ID)
+				report.Stale = append(report.Stale, StaleExecution{StreamID: streamID, Execution: e, Err: err})
+				continue
+			}
+			age := now().Sub(startedAt)
+			if age <= j.MaxAge {
+				continue
+			}
+			stale := StaleExecution{StreamID: streamID, Execution: e, Age: age}
+			if !j.DryRun {
+				_, stale.Err = j.Streams.AbortExecution(ctx, streamID, e.ID)
+				stale.Aborted = stale.Err == nil
+			}
+			report.Stale = append(report.Stale, stale)
+		}
+	}
+	return report, nil
+}
+
+// Run sweeps every interval until ctx is done, passing each sweep's results to report. It returns ctx.Err().
+func (j *Janitor) Run(ctx context.Context, interval time.Duration, report func(*JanitorReport, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// a tick can be ready alongside ctx.Done, so the context is checked before every sweep.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r, err := j.Sweep(ctx)
+		if report != nil {
+			report(r, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *Janitor) allStreamIDs(ctx context.Context) ([]int, error) {
+	var ids []int
+	err := eachPaged(ctx, j.Streams.List, func(s *StreamDataset) bool {
+		ids = append(ids, s.ID)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (j *Janitor) activeExecutions(ctx context.Context, streamID int) ([]*StreamExecution, error) {
+	list := func(ctx context.Context, limit, offset int) ([]*StreamExecution, *http.Response, error) {
+		return j.Streams.ListExecutions(ctx, streamID, limit, offset)
+	}
+	var active []*StreamExecution
+	err := eachPaged(ctx, list, func(e *StreamExecution) bool {
+		if e.CurrentState == ExecutionStateActive {
+			active = append(active, e)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return active, nil
+}
@@ -0,0 +1,123 @@
+package domo
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func janitorTestClient(t *testing.T, aborted *[]string) (*Client, func()) {
+	client, server := testClientRoutesV2(t,
+		testRoute{"GET /v1/streams", testBody(`[{"id": 1}, {"id": 2}]`)},
+		testRoute{"GET /v1/streams/1/executions", testBody(`[
+			{"id": 10, "currentState": "ACTIVE", "startedAt": "2019-03-01T00:00:00Z"},
+			{"id": 11, "currentState": "SUCCESS", "startedAt": "2019-03-01T00:00:00Z"},
+			{"id": 12, "currentState": "ACTIVE", "startedAt": "2019-03-04T23:30:00Z"}
+		]`)},
+		testRoute{"GET /v1/streams/2/executions", testBody(`[{"id": 20, "currentState": "ACTIVE", "startedAt": "2019-03-02T00:00:00Z"}]`)},
+		testRoute{"GET /v1/streams/3/executions", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error": {"status": 500, "message": "domo err msg"}}`, http.StatusInternalServerError)
+		}},
+		testRoute{"PUT /v1/streams/*/executions/*/abort", func(w http.ResponseWriter, r *http.Request) {
+			*aborted = append(*aborted, r.URL.Path)
+		}},
+	)
+	return client, server.Close
+}
+
+func TestJanitor_Sweep(t *testing.T) {
+	var aborted []string
+	client, closeServer := janitorTestClient(t, &aborted)
+	defer closeServer()
+	janitor := NewJanitor(client, time.Hour)
+	janitor.now = func() time.Time { return time.Date(2019, 3, 5, 0, 0, 0, 0, time.UTC) }
+
+	report, err := janitor.Sweep(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.StreamsScanned != 2 {
+		t.Errorf("Expected 2 streams scanned, got %d", report.StreamsScanned)
+	}
+	if len(report.Stale) != 2 || report.Stale[0].Execution.ID != 10 || report.Stale[1].Execution.ID != 20 {
+		t.Fatalf("Expected executions 10 and 20 to be stale, got %+v", report.Stale)
+	}
+	if !report.Stale[0].Aborted || report.Stale[0].Age != 96*time.Hour {
+		t.Errorf("Unexpected stale execution %+v", report.Stale[0])
+	}
+	expected := "/v1/streams/1/executions/10/abort\n/v1/streams/2/executions/20/abort"
+	if strings.Join(aborted, "\n") != expected {
+		t.Errorf("Unexpected aborts:\n%s", strings.Join(aborted, "\n"))
+	}
+}
+
+func TestJanitor_SweepStreamFails(t *testing.T) {
+	var aborted []string
+	client, closeServer := janitorTestClient(t, &aborted)
+	defer closeServer()
+	janitor := NewJanitor(client, time.Hour)
+	janitor.now = func() time.Time { return time.Date(2019, 3, 5, 0, 0, 0, 0, time.UTC) }
+	janitor.AllowList = []int{3, 2}
+
+	report, err := janitor.Sweep(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].StreamID != 3 || report.Failed[0].Err == nil {
+		t.Errorf("Expected stream 3 to fail, got %+v", report.Failed)
+	}
+	if report.StreamsScanned != 1 || len(report.Stale) != 1 || report.Stale[0].StreamID != 2 {
+		t.Errorf("Expected stream 2 to still be swept, got %+v", report)
+	}
+}
+
+func TestJanitor_SweepDryRunAllowList(t *testing.T) {
+	var aborted []string
+	client, closeServer := janitorTestClient(t, &aborted)
+	defer closeServer()
+	janitor := NewJanitor(client, time.Hour)
+	janitor.now = func() time.Time { return time.Date(2019, 3, 5, 0, 0, 0, 0, time.UTC) }
+	janitor.DryRun = true
+	janitor.AllowList = []int{2}
+
+	report, err := janitor.Sweep(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.StreamsScanned != 1 || len(report.Stale) != 1 || report.Stale[0].StreamID != 2 {
+		t.Errorf("Expected only stream 2 to be scanned, got %+v", report)
+	}
+	if report.Stale[0].Aborted || len(aborted) != 0 {
+		t.Error("Expected nothing to be aborted on a dry run")
+	}
+}
+
+func TestJanitor_Run(t *testing.T) {
+	var aborted []string
+	client, closeServer := janitorTestClient(t, &aborted)
+	defer closeServer()
+	janitor := NewJanitor(client, time.Hour)
+	janitor.DryRun = true
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sweeps := 0
+	err := janitor.Run(ctx, time.Millisecond, func(r *JanitorReport, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		sweeps++
+		if sweeps == 2 {
+			cancel()
+			// let a tick be pending alongside the cancellation.
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected Run to return the context error, got %v", err)
+	}
+	if sweeps != 2 {
+		t.Errorf("Expected 2 sweeps, got %d", sweeps)
+	}
+}
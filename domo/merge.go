@@ -0,0 +1,175 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// MergeOptions configures how incoming rows are merged into a dataset's existing rows.
+type MergeOptions[T any] struct {
+	// DeleteMissing removes existing rows whose key isn't in the incoming rows,
+	// treating the incoming rows as a full snapshot of the dataset.
+	DeleteMissing bool
+	// IsDelete, if set, marks incoming rows as deletes. The existing row with the
+	// same key is removed and the incoming row itself isn't written.
+	IsDelete func(row T) bool
+}
+
+// MergeResult reports the row changes made by a merge.
+type MergeResult struct {
+	Inserted  int
+	Updated   int
+	Deleted   int
+	Unchanged int
+	// Duplicates is the number of existing rows dropped because a later existing row repeated their key.
+	Duplicates int
+	// Execution is the stream execution that replaced the dataset's data. It's nil for MergeRows.
+	Execution *StreamExecution
+}
+
+// MergeRows merges incoming rows into existing rows by the key columns of T, set with the
+// key tag option i.e. `domo:"id,key"`. Updated rows keep their position, inserted rows are
+// added at the end in the order they came in. When an incoming key is repeated the last row wins.
+// Existing rows repeating a key are collapsed the same way: the last row takes the first row's
+// position and the others are counted as Duplicates.
+func MergeRows[T any](existing, incoming []T, opts MergeOptions[T]) ([]T, MergeResult, error) {
+	var result MergeResult
+	si, err := getStructInfo(typeOf[T]())
+	if err != nil {
+		return nil, result, err
+	}
+	keyFields := si.keyFields()
+	if len(keyFields) == 0 {
+		return nil, result, fmt.Errorf("%s has no key columns, tag at least one field with the %s option", typeOf[T](), tagOptionKey)
+	}
+
+	merged := make([]T, len(existing))
+	copy(merged, existing)
+	positions := make(map[string]int, len(existing))
+	deleted := make(map[int]bool)
+	duplicates := make(map[int]bool)
+	// origins maps each position to the existing row kept there, to tell updates from unchanged rows.
+	origins := make([]int, len(existing))
+	for i := range merged {
+		origins[i] = i
+		k, err := rowKey(keyFields, reflect.Indirect(reflect.ValueOf(merged[i])))
+		if err != nil {
+			return nil, result, fmt.Errorf("existing row %d: %v", i, err)
+		}
+		// a repeated existing key is collapsed into the first row with the key.
+		if pos, ok := positions[k]; ok {
+			merged[pos] = merged[i]
+			origins[pos] = i
+			duplicates[i] = true
+			continue
+		}
+		positions[k] = i
+	}
+
+	seen := make(map[string]bool, len(incoming))
+	for i, row := range incoming {
+		k, err := rowKey(keyFields, reflect.Indirect(reflect.ValueOf(row)))
+		if err != nil {
+			return nil, result, fmt.Errorf("incoming row %d: %v", i, err)
+		}
+		seen[k] = true
+		pos, exists := positions[k]
+		if opts.IsDelete != nil && opts.IsDelete(row) {
+			if exists {
+				deleted[pos] = true
+			}
+			continue
+		}
+		if !exists {
+			positions[k] = len(merged)
+			merged = append(merged, row)
+			continue
+		}
+		// a delete earlier in the batch is superseded by this row.
+		delete(deleted, pos)
+		merged[pos] = row
+	}
+	if opts.DeleteMissing {
+		for k, pos := range positions {
+			if !seen[k] {
+				deleted[pos] = true
+			}
+		}
+	}
+
+	out := make([]T, 0, len(merged))
+	for i, row := range merged {
+		if duplicates[i] {
+			result.Duplicates++
+			continue
+		}
+		if deleted[i] {
+			if i < len(existing) {
+				result.Deleted++
+			}
+			continue
+		}
+		out = append(out, row)
+		if i >= len(existing) {
+			result.Inserted++
+			continue
+		}
+		same, err := sameRow(si, reflect.ValueOf(existing[origins[i]]), reflect.ValueOf(row))
+		if err != nil {
+			return nil, result, err
+		}
+		if same {
+			result.Unchanged++
+		} else {
+			result.Updated++
+		}
+	}
+	return out, result, nil
+}
+
+// sameRow reports whether two rows serialize to the same cells.
+func sameRow(si *structInfo, a, b reflect.Value) (bool, error) {
+	a, b = reflect.Indirect(a), reflect.Indirect(b)
+	for _, f := range si.Fields {
+		ca, err := f.formatCell(a)
+		if err != nil {
+			return false, err
+		}
+		cb, err := f.formatCell(b)
+		if err != nil {
+			return false, err
+		}
+		if ca != cb {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Merge downloads the stream dataset's current rows, merges rows into them with MergeRows and
+// REPLACEs the dataset with the result through a single stream execution. Domo only offers
+// APPEND and REPLACE loads, so the whole dataset is re-uploaded. An execution can't be empty, so
+// nothing is uploaded when there are no rows to merge, and a merge deleting every row is an error.
+func (s *TypedStream[T]) Merge(ctx context.Context, rows []T, opts MergeOptions[T]) (*MergeResult, error) {
+	existing, err := s.ReadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	merged, result, err := MergeRows(existing, rows, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(merged) == 0 {
+		if result.Deleted > 0 {
+			return &result, fmt.Errorf("merge would delete all %d rows of stream %d", result.Deleted, s.ID)
+		}
+		return &result, nil
+	}
+	execution, err := s.Replace(ctx, merged)
+	if err != nil {
+		return nil, err
+	}
+	result.Execution = execution
+	return &result, nil
+}
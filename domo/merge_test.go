@@ -0,0 +1,150 @@
+package domo
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type mergeSample struct {
+	ID      int    `domo:"id,key"`
+	Region  string `domo:"region,key"`
+	Name    string `domo:"name"`
+	Deleted bool   `domo:"-"`
+}
+
+func TestMergeRows(t *testing.T) {
+	existing := []mergeSample{
+		{ID: 1, Region: "us", Name: "a"},
+		{ID: 2, Region: "us", Name: "b"},
+		{ID: 1, Region: "eu", Name: "c"},
+		{ID: 3, Region: "us", Name: "d"},
+	}
+	incoming := []mergeSample{
+		{ID: 2, Region: "us", Name: "b2"},
+		{ID: 4, Region: "us", Name: "e"},
+		{ID: 1, Region: "eu", Name: "c"},
+		{ID: 3, Region: "us", Deleted: true},
+		{ID: 4, Region: "us", Name: "e2"},
+	}
+	merged, result, err := MergeRows(existing, incoming, MergeOptions[mergeSample]{
+		IsDelete: func(row mergeSample) bool { return row.Deleted },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a", "b2", "c", "e2"}
+	if len(merged) != len(expected) {
+		t.Fatalf("Expected %d rows, got %+v", len(expected), merged)
+	}
+	for i, row := range merged {
+		if row.Name != expected[i] {
+			t.Errorf("Expected row %d to be %s, got %+v", i, expected[i], row)
+		}
+	}
+	if result.Inserted != 1 || result.Updated != 1 || result.Deleted != 1 || result.Unchanged != 2 {
+		t.Errorf("Unexpected merge result %+v", result)
+	}
+}
+
+func TestMergeRows_DeleteMissing(t *testing.T) {
+	existing := []mergeSample{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+	incoming := []mergeSample{{ID: 2, Name: "b"}}
+	merged, result, err := MergeRows(existing, incoming, MergeOptions[mergeSample]{DeleteMissing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 1 || merged[0].ID != 2 {
+		t.Errorf("Expected only row 2 to remain, got %+v", merged)
+	}
+	if result.Deleted != 1 || result.Unchanged != 1 {
+		t.Errorf("Unexpected merge result %+v", result)
+	}
+}
+
+func TestMergeRows_DuplicateExistingKeys(t *testing.T) {
+	existing := []mergeSample{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}, {ID: 1, Name: "a2"}, {ID: 1, Name: "a3"}}
+	incoming := []mergeSample{{ID: 2, Name: "b2"}, {ID: 1, Name: "a3"}}
+	merged, result, err := MergeRows(existing, incoming, MergeOptions[mergeSample]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 2 || merged[0].Name != "a3" || merged[1].Name != "b2" {
+		t.Errorf("Expected one row per key, got %+v", merged)
+	}
+	// the incoming row is the same as the kept duplicate, so it's unchanged.
+	if result.Duplicates != 2 || result.Deleted != 0 || result.Updated != 1 || result.Unchanged != 1 {
+		t.Errorf("Unexpected merge result %+v", result)
+	}
+}
+
+func TestMergeRows_NoKey(t *testing.T) {
+	if _, _, err := MergeRows([]DomoAddress{}, nil, MergeOptions[DomoAddress]{}); err == nil {
+		t.Error("Expected an error merging a struct without key columns")
+	}
+}
+
+func TestTypedStream_Merge(t *testing.T) {
+	var uploaded string
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/datasets/abc/data":
+			w.Write([]byte("id,region,name\n1,us,a\n2,us,b\n"))
+		case r.Method == "GET" && r.URL.Path == "/v1/streams/42":
+			w.Write([]byte(`{"id": 42, "updateMethod": "APPEND"}`))
+		case r.Method == "PATCH":
+			w.Write([]byte(`{"id": 42, "updateMethod": "REPLACE"}`))
+		case strings.HasSuffix(r.URL.Path, "/executions"):
+			w.Write([]byte(`{"id": 1}`))
+		case strings.Contains(r.URL.Path, "/part/"):
+			b, _ := ioutil.ReadAll(r.Body)
+			uploaded += string(b)
+			w.Write([]byte(`{"id": 1}`))
+		case strings.HasSuffix(r.URL.Path, "/commit"):
+			w.Write([]byte(`{"id": 1, "currentState": "SUCCESS"}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	ctx := context.Background()
+	defer server.Close()
+
+	stream := NewTypedStream[mergeSample](client, 42)
+	stream.DatasetID = "abc"
+	result, err := stream.Merge(ctx, []mergeSample{{ID: 2, Region: "us", Name: "b2"}, {ID: 3, Region: "us", Name: "c"}}, MergeOptions[mergeSample]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != "1,us,a\n2,us,b2\n3,us,c\n" {
+		t.Errorf("Unexpected uploaded csv %q", uploaded)
+	}
+	if result.Inserted != 1 || result.Updated != 1 || result.Unchanged != 1 || result.Execution == nil {
+		t.Errorf("Unexpected merge result %+v", result)
+	}
+}
+
+func TestTypedStream_MergeEmpty(t *testing.T) {
+	data := "id,region,name\n"
+	client, server := testClientRoutesV2(t,
+		testRoute{"GET /v1/datasets/abc/data", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(data))
+		}},
+	)
+	ctx := context.Background()
+	defer server.Close()
+
+	stream := NewTypedStream[mergeSample](client, 42)
+	stream.DatasetID = "abc"
+	result, err := stream.Merge(ctx, nil, MergeOptions[mergeSample]{})
+	if err != nil || result.Execution != nil {
+		t.Errorf("Expected nothing to be uploaded, got %+v %v", result, err)
+	}
+
+	data = "id,region,name\n1,us,a\n"
+	result, err = stream.Merge(ctx, nil, MergeOptions[mergeSample]{DeleteMissing: true})
+	if err == nil || result.Deleted != 1 || result.Execution != nil {
+		t.Errorf("Expected deleting every row to fail without uploading, got %+v %v", result, err)
+	}
+}
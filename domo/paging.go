@@ -0,0 +1,44 @@
+package domo
+
+import (
+	"context"
+	"net/http"
+)
+
+// maxPageSize is the largest limit the Domo list endpoints accept.
+const maxPageSize = 500
+
+// pageLister lists up to limit items starting at offset, i.e. UsersService.List.
+type pageLister[T any] func(ctx context.Context, limit, offset int) ([]T, *http.Response, error)
+
+// eachPaged pages through list, calling fn with each item until it returns false. Paging stops at
+// the first page shorter than maxPageSize.
+func eachPaged[T any](ctx context.Context, list pageLister[T], fn func(item T) bool) error {
+	for offset := 0; ; offset += maxPageSize {
+		items, _, err := list(ctx, maxPageSize, offset)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if !fn(item) {
+				return nil
+			}
+		}
+		if len(items) < maxPageSize {
+			return nil
+		}
+	}
+}
+
+// allPaged pages through list and returns every item.
+func allPaged[T any](ctx context.Context, list pageLister[T]) ([]T, error) {
+	var all []T
+	err := eachPaged(ctx, list, func(item T) bool {
+		all = append(all, item)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
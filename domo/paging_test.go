@@ -0,0 +1,57 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAllPaged(t *testing.T) {
+	var offsets []int
+	list := func(ctx context.Context, limit, offset int) ([]int, *http.Response, error) {
+		offsets = append(offsets, offset)
+		n := limit
+		if offset >= 2*limit {
+			n = 3
+		}
+		page := make([]int, n)
+		for i := range page {
+			page[i] = offset + i
+		}
+		return page, nil, nil
+	}
+
+	all, err := allPaged(context.Background(), list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2*maxPageSize+3 || all[len(all)-1] != 2*maxPageSize+2 {
+		t.Errorf("Expected %d items, got %d", 2*maxPageSize+3, len(all))
+	}
+	if fmt.Sprint(offsets) != fmt.Sprint([]int{0, maxPageSize, 2 * maxPageSize}) {
+		t.Errorf("Unexpected offsets %v", offsets)
+	}
+
+	offsets = nil
+	seen := 0
+	err = eachPaged(context.Background(), list, func(item int) bool {
+		seen++
+		return item < 10
+	})
+	if err != nil || seen != 11 || len(offsets) != 1 {
+		t.Errorf("Expected paging to stop at item 10, saw %d items on %d pages, err %v", seen, len(offsets), err)
+	}
+}
+
+func TestAllPaged_Error(t *testing.T) {
+	list := func(ctx context.Context, limit, offset int) ([]int, *http.Response, error) {
+		if offset > 0 {
+			return nil, nil, fmt.Errorf("page %d failed", offset)
+		}
+		return make([]int, limit), nil, nil
+	}
+	if all, err := allPaged(context.Background(), list); err == nil || all != nil {
+		t.Errorf("Expected the page error, got %d items and %v", len(all), err)
+	}
+}
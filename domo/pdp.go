@@ -0,0 +1,244 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// PDP policy types.
+const (
+	// PolicyTypeUser policies are created by users and filter the rows of their users and groups.
+	PolicyTypeUser = "user"
+	// PolicyTypeSystem is the All Rows policy every PDP enabled dataset has. It can't be deleted.
+	PolicyTypeSystem = "system"
+)
+
+// PDP policy filter operators.
+const (
+	FilterOperatorEquals           = "EQUALS"
+	FilterOperatorLike             = "LIKE"
+	FilterOperatorGreaterThan      = "GREATER_THAN"
+	FilterOperatorLessThan         = "LESS_THAN"
+	FilterOperatorGreaterThanEqual = "GREATER_THAN_EQUAL"
+	FilterOperatorLessThanEqual    = "LESS_THAN_EQUAL"
+	FilterOperatorBetween          = "BETWEEN"
+	FilterOperatorBeginsWith       = "BEGINS_WITH"
+	FilterOperatorEndsWith         = "ENDS_WITH"
+	FilterOperatorContains         = "CONTAINS"
+)
+
+// ListPolicies lists the PDP policies of the dataset.
+func (s *DatasetsService) ListPolicies(ctx context.Context, id string) ([]*Policy, *http.Response, error) {
+	u := fmt.Sprintf("v1/datasets/%s/policies", id)
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var policies []*Policy
+	resp, err := s.client.Do(ctx, req, &policies)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return policies, resp, nil
+}
+
+// GetPolicy gets a PDP policy of the dataset by policy id.
+func (s *DatasetsService) GetPolicy(ctx context.Context, id string, policyID int) (*Policy, *http.Response, error) {
+	u := fmt.Sprintf("v1/datasets/%s/policies/%d", id, policyID)
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var p *Policy
+	resp, err := s.client.Do(ctx, req, &p)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return p, resp, nil
+}
+
+// CreatePolicy creates a PDP policy on the dataset.
+func (s *DatasetsService) CreatePolicy(ctx context.Context, id string, policy Policy) (*Policy, *http.Response, error) {
+	u := fmt.Sprintf("v1/datasets/%s/policies", id)
+	req, err := s.client.NewRequest("POST", u, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var p *Policy
+	resp, err := s.client.Do(ctx, req, &p)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return p, resp, nil
+}
+
+// UpdatePolicy replaces the PDP policy with policyID on the dataset with policy.
+func (s *DatasetsService) UpdatePolicy(ctx context.Context, id string, policyID int, policy Policy) (*Policy, *http.Response, error) {
+	u := fmt.Sprintf("v1/datasets/%s/policies/%d", id, policyID)
+	req, err := s.client.NewRequest("PUT", u, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var p *Policy
+	resp, err := s.client.Do(ctx, req, &p)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return p, resp, nil
+}
+
+// DeletePolicy deletes a PDP policy from the dataset.
+func (s *DatasetsService) DeletePolicy(ctx context.Context, id string, policyID int) (*http.Response, error) {
+	u := fmt.Sprintf("v1/datasets/%s/policies/%d", id, policyID)
+	req, err := s.client.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(ctx, req, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// PolicyReconcileResult lists the changes ReconcilePolicies made, or would make on a dry run.
+type PolicyReconcileResult struct {
+	Created   []Policy
+	Updated   []Policy
+	Deleted   []Policy
+	Unchanged int
+}
+
+// ReconcilePolicies makes the dataset's user policies match desired with the fewest changes.
+// Policies are matched by name: missing policies are created, ones that differ are updated
+// and user policies that aren't desired are deleted. When several existing policies share a
+// desired name one is kept, preferably one already matching, and the rest are deleted. The
+// system All Rows policy is never deleted. With dryRun set the changes are only reported.
+func (s *DatasetsService) ReconcilePolicies(ctx context.Context, id string, desired []Policy, dryRun bool) (*PolicyReconcileResult, error) {
+	wanted := make(map[string]bool, len(desired))
+	for _, p := range desired {
+		if p.Name == "" {
+			return nil, fmt.Errorf("desired policies must have a name")
+		}
+		if wanted[p.Name] {
+			return nil, fmt.Errorf("desired policy %q is listed more than once", p.Name)
+		}
+		wanted[p.Name] = true
+	}
+
+	current, _, err := s.ListPolicies(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string][]*Policy, len(current))
+	for _, p := range current {
+		byName[p.Name] = append(byName[p.Name], p)
+	}
+	// the policy kept for each desired name. Extra policies sharing the name are deleted.
+	kept := make(map[*Policy]bool, len(desired))
+
+	result := &PolicyReconcileResult{}
+	for _, p := range desired {
+		e := keptPolicy(byName[p.Name], p)
+		if e == nil {
+			if !dryRun {
+				created, _, err := s.CreatePolicy(ctx, id, p)
+				if err != nil {
+					return result, fmt.Errorf("creating policy %q: %v", p.Name, err)
+				}
+				p = *created
+			}
+			result.Created = append(result.Created, p)
+			continue
+		}
+		kept[e] = true
+		if samePolicy(*e, p) {
+			result.Unchanged++
+			continue
+		}
+		p.ID = e.ID
+		if !dryRun {
+			updated, _, err := s.UpdatePolicy(ctx, id, e.ID, p)
+			if err != nil {
+				return result, fmt.Errorf("updating policy %q: %v", p.Name, err)
+			}
+			p = *updated
+		}
+		result.Updated = append(result.Updated, p)
+	}
+	for _, e := range current {
+		if kept[e] || e.PolicyType == PolicyTypeSystem {
+			continue
+		}
+		if !dryRun {
+			if _, err := s.DeletePolicy(ctx, id, e.ID); err != nil {
+				return result, fmt.Errorf("deleting policy %q: %v", e.Name, err)
+			}
+		}
+		result.Deleted = append(result.Deleted, *e)
+	}
+	return result, nil
+}
+
+// keptPolicy picks the existing policy to reconcile with p out of the ones sharing its name,
+// preferring one that already matches. It returns nil if there are none.
+func keptPolicy(existing []*Policy, p Policy) *Policy {
+	if len(existing) == 0 {
+		return nil
+	}
+	for _, e := range existing {
+		if samePolicy(*e, p) {
+			return e
+		}
+	}
+	return existing[0]
+}
+
+// samePolicy compares the type, users, groups and filters of two policies, ignoring order.
+func samePolicy(a, b Policy) bool {
+	if a.PolicyType != b.PolicyType && b.PolicyType != "" {
+		return false
+	}
+	return reflect.DeepEqual(sortedInts(a.UserIDs), sortedInts(b.UserIDs)) &&
+		reflect.DeepEqual(sortedInts(a.GroupIDs), sortedInts(b.GroupIDs)) &&
+		reflect.DeepEqual(sortedFilters(a.Filters), sortedFilters(b.Filters))
+}
+
+func sortedInts(ids []int) []int {
+	sorted := append([]int{}, ids...)
+	sort.Ints(sorted)
+	return sorted
+}
+
+func sortedFilters(filters []Filter) []Filter {
+	sorted := make([]Filter, len(filters))
+	for i, f := range filters {
+		f.Values = append([]string{}, f.Values...)
+		if f.Operator != FilterOperatorBetween {
+			sort.Strings(f.Values)
+		}
+		sorted[i] = f
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return fmt.Sprint(sorted[i]) < fmt.Sprint(sorted[j])
+	})
+	return sorted
+}
@@ -0,0 +1,259 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PolicyEvaluator works out locally which rows of a PDP enabled dataset a user can see, i.e. to
+// check policies in tests and audits without logging in as the user.
+//
+// A user sees the rows matched by any policy listing them or one of their groups. A row matches a
+// policy when it matches all of the policy's filters, and a policy without filters, like the All
+// Rows policy, matches every row. Users without a policy see no rows. Admin and dataset owner
+// access isn't modeled.
+//
+// Filters match a cell when it matches any of the filter's values, BETWEEN takes a low and a high
+// value. LIKE values use % and _ wildcards. Empty cells are treated as nulls and never match, even
+// in a negated filter. Comparisons are numeric when both sides are numbers, by time when both are
+// dates and by string otherwise. String comparisons are case sensitive.
+type PolicyEvaluator struct {
+	UserID   int
+	GroupIDs []int
+	// Policies that apply to the user.
+	Policies []Policy
+}
+
+// NewPolicyEvaluator returns a PolicyEvaluator for the user with the given groups, keeping the
+// policies that apply to them.
+func NewPolicyEvaluator(policies []Policy, userID int, groupIDs []int) *PolicyEvaluator {
+	e := &PolicyEvaluator{UserID: userID, GroupIDs: groupIDs}
+	inGroup := make(map[int]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		inGroup[id] = true
+	}
+	for _, p := range policies {
+		applies := false
+		for _, id := range p.UserIDs {
+			applies = applies || id == userID
+		}
+		for _, id := range p.GroupIDs {
+			applies = applies || inGroup[id]
+		}
+		if applies {
+			e.Policies = append(e.Policies, p)
+		}
+	}
+	return e
+}
+
+// PolicyEvaluator lists the dataset's policies and returns a PolicyEvaluator for the user. The
+// user's groups are found by checking the members of every group the policies list.
+func (s *DatasetsService) PolicyEvaluator(ctx context.Context, id string, userID int) (*PolicyEvaluator, error) {
+	policies, _, err := s.ListPolicies(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var all []Policy
+	checked := make(map[int]bool)
+	var groupIDs []int
+	for _, p := range policies {
+		all = append(all, *p)
+		for _, groupID := range p.GroupIDs {
+			if checked[groupID] {
+				continue
+			}
+			checked[groupID] = true
+			members, err := s.client.Groups.AllUserIDs(ctx, groupID)
+			if err != nil {
+				return nil, fmt.Errorf("listing members of group %d: %v", groupID, err)
+			}
+			for _, m := range members {
+				if m == userID {
+					groupIDs = append(groupIDs, groupID)
+					break
+				}
+			}
+		}
+	}
+	return NewPolicyEvaluator(all, userID, groupIDs), nil
+}
+
+// Match reports whether the user can see a row. cell returns the row's value for a column and
+// false if the row has no such column.
+func (e *PolicyEvaluator) Match(cell func(column string) (string, bool)) (bool, error) {
+	for _, p := range e.Policies {
+		ok, err := matchPolicy(p, cell)
+		if err != nil {
+			return false, fmt.Errorf("policy %q: %v", p.Name, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FilterRows returns the rows the user can see, with header naming the columns of each row,
+// i.e. the Columns and StringRows of a QueryResult.
+func (e *PolicyEvaluator) FilterRows(header []string, rows [][]string) ([][]string, error) {
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		index[h] = i
+	}
+	var visible [][]string
+	for n, row := range rows {
+		ok, err := e.Match(func(column string) (string, bool) {
+			i, found := index[column]
+			if !found || i >= len(row) {
+				return "", false
+			}
+			return row[i], true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", n, err)
+		}
+		if ok {
+			visible = append(visible, row)
+		}
+	}
+	return visible, nil
+}
+
+// FilterPolicyRows returns the rows of a typed dataset the evaluator's user can see. Cells are
+// compared the way they're written to CSV.
+func FilterPolicyRows[T any](e *PolicyEvaluator, rows []T) ([]T, error) {
+	si, err := getStructInfo(typeOf[T]())
+	if err != nil {
+		return nil, err
+	}
+	var visible []T
+	for n, row := range rows {
+		v := reflect.Indirect(reflect.ValueOf(row))
+		var cellErr error
+		ok, err := e.Match(func(column string) (string, bool) {
+			for _, f := range si.Fields {
+				if f.matchesKey(column) {
+					c, err := f.formatCell(v)
+					if err != nil {
+						cellErr = err
+					}
+					return c, true
+				}
+			}
+			return "", false
+		})
+		if err == nil {
+			err = cellErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", n, err)
+		}
+		if ok {
+			visible = append(visible, row)
+		}
+	}
+	return visible, nil
+}
+
+func matchPolicy(p Policy, cell func(column string) (string, bool)) (bool, error) {
+	for _, f := range p.Filters {
+		c, ok := cell(f.Column)
+		if !ok {
+			return false, fmt.Errorf("no column %q", f.Column)
+		}
+		match, err := matchFilter(f, c)
+		if err != nil {
+			return false, err
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchFilter reports whether a cell passes a PDP filter.
+func matchFilter(f Filter, cell string) (bool, error) {
+	if cell == "" {
+		return false, nil
+	}
+	var match bool
+	switch f.Operator {
+	case FilterOperatorEquals:
+		match = anyValue(f.Values, func(v string) bool { return compareValues(cell, v) == 0 })
+	case FilterOperatorLike:
+		match = anyValue(f.Values, func(v string) bool { return likePattern(v).MatchString(cell) })
+	case FilterOperatorBeginsWith:
+		match = anyValue(f.Values, func(v string) bool { return strings.HasPrefix(cell, v) })
+	case FilterOperatorEndsWith:
+		match = anyValue(f.Values, func(v string) bool { return strings.HasSuffix(cell, v) })
+	case FilterOperatorContains:
+		match = anyValue(f.Values, func(v string) bool { return strings.Contains(cell, v) })
+	case FilterOperatorGreaterThan:
+		match = anyValue(f.Values, func(v string) bool { return compareValues(cell, v) > 0 })
+	case FilterOperatorGreaterThanEqual:
+		match = anyValue(f.Values, func(v string) bool { return compareValues(cell, v) >= 0 })
+	case FilterOperatorLessThan:
+		match = anyValue(f.Values, func(v string) bool { return compareValues(cell, v) < 0 })
+	case FilterOperatorLessThanEqual:
+		match = anyValue(f.Values, func(v string) bool { return compareValues(cell, v) <= 0 })
+	case FilterOperatorBetween:
+		if len(f.Values) != 2 {
+			return false, fmt.Errorf("%s filter on %q needs 2 values, but has %d", f.Operator, f.Column, len(f.Values))
+		}
+		match = compareValues(cell, f.Values[0]) >= 0 && compareValues(cell, f.Values[1]) <= 0
+	default:
+		return false, fmt.Errorf("unknown filter operator %q", f.Operator)
+	}
+	return match != f.Not, nil
+}
+
+func anyValue(values []string, fn func(v string) bool) bool {
+	for _, v := range values {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValues compares two cells as numbers, times or strings, returning -1, 0 or 1.
+func compareValues(a, b string) int {
+	if _, err := strconv.ParseFloat(a, 64); err == nil {
+		if _, err := strconv.ParseFloat(b, 64); err == nil {
+			c, _ := compareCells(ColumnTypeDouble, a, b)
+			return c
+		}
+	}
+	if _, err := parseCSVTime(a); err == nil {
+		if _, err := parseCSVTime(b); err == nil {
+			c, _ := compareCells(ColumnTypeDatetime, a, b)
+			return c
+		}
+	}
+	c, _ := compareCells(ColumnTypeString, a, b)
+	return c
+}
+
+// likePattern converts a SQL LIKE pattern to a regexp.
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile("(?s)" + b.String())
+}
@@ -0,0 +1,133 @@
+package domo
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestMatchFilter(t *testing.T) {
+	tests := []struct {
+		filter Filter
+		cell   string
+		match  bool
+	}{
+		{Filter{Operator: FilterOperatorEquals, Values: []string{"West", "East"}}, "East", true},
+		{Filter{Operator: FilterOperatorEquals, Values: []string{"West"}}, "west", false},
+		{Filter{Operator: FilterOperatorEquals, Values: []string{"West"}, Not: true}, "East", true},
+		{Filter{Operator: FilterOperatorEquals, Values: []string{"West"}, Not: true}, "", false},
+		{Filter{Operator: FilterOperatorEquals, Values: []string{"10"}}, "10.0", true},
+		{Filter{Operator: FilterOperatorLike, Values: []string{"W_st%"}}, "Western", true},
+		{Filter{Operator: FilterOperatorLike, Values: []string{"W.st"}}, "West", false},
+		{Filter{Operator: FilterOperatorBeginsWith, Values: []string{"We"}}, "West", true},
+		{Filter{Operator: FilterOperatorEndsWith, Values: []string{"st"}}, "East", true},
+		{Filter{Operator: FilterOperatorContains, Values: []string{"as"}}, "East", true},
+		{Filter{Operator: FilterOperatorGreaterThan, Values: []string{"9"}}, "10", true},
+		{Filter{Operator: FilterOperatorGreaterThanEqual, Values: []string{"10"}}, "10", true},
+		{Filter{Operator: FilterOperatorLessThan, Values: []string{"2019-03-04"}}, "2019-03-03T23:00:00Z", true},
+		{Filter{Operator: FilterOperatorLessThanEqual, Values: []string{"b"}}, "c", false},
+		{Filter{Operator: FilterOperatorBetween, Values: []string{"5", "10"}}, "7", true},
+		{Filter{Operator: FilterOperatorBetween, Values: []string{"5", "10"}}, "11", false},
+		{Filter{Operator: FilterOperatorBetween, Values: []string{"5", "10"}, Not: true}, "11", true},
+	}
+	for _, test := range tests {
+		match, err := matchFilter(test.filter, test.cell)
+		if err != nil {
+			t.Errorf("%+v: %v", test.filter, err)
+			continue
+		}
+		if match != test.match {
+			t.Errorf("Expected %+v on %q to be %v", test.filter, test.cell, test.match)
+		}
+	}
+	if _, err := matchFilter(Filter{Operator: "NEAR"}, "x"); err == nil {
+		t.Error("Expected an error for an unknown operator")
+	}
+	if _, err := matchFilter(Filter{Operator: FilterOperatorBetween, Values: []string{"1"}}, "x"); err == nil {
+		t.Error("Expected an error for a BETWEEN filter with one value")
+	}
+}
+
+var pdpEvalPolicies = []Policy{
+	{Name: "All Rows", PolicyType: PolicyTypeSystem, UserIDs: []int{1}},
+	{Name: "West", PolicyType: PolicyTypeUser, UserIDs: []int{2}, Filters: []Filter{
+		{Column: "region", Operator: FilterOperatorEquals, Values: []string{"West"}},
+	}},
+	{Name: "Big East", PolicyType: PolicyTypeUser, GroupIDs: []int{7}, Filters: []Filter{
+		{Column: "region", Operator: FilterOperatorEquals, Values: []string{"East"}},
+		{Column: "amount", Operator: FilterOperatorGreaterThan, Values: []string{"100"}},
+	}},
+}
+
+type pdpRow struct {
+	Region string  `domo:"region"`
+	Amount float64 `domo:"amount"`
+}
+
+func TestPolicyEvaluator_FilterRows(t *testing.T) {
+	header := []string{"region", "amount"}
+	rows := [][]string{{"West", "50"}, {"East", "500"}, {"East", "5"}, {"North", "1000"}}
+
+	tests := []struct {
+		userID   int
+		groupIDs []int
+		expected int
+	}{
+		{1, nil, 4},
+		{2, nil, 1},
+		{3, []int{7}, 1},
+		{2, []int{7}, 2},
+		{4, nil, 0},
+	}
+	for _, test := range tests {
+		e := NewPolicyEvaluator(pdpEvalPolicies, test.userID, test.groupIDs)
+		visible, err := e.FilterRows(header, rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(visible) != test.expected {
+			t.Errorf("Expected user %d in groups %v to see %d rows, got %v", test.userID, test.groupIDs, test.expected, visible)
+		}
+	}
+
+	e := NewPolicyEvaluator(pdpEvalPolicies, 2, nil)
+	if _, err := e.FilterRows([]string{"amount"}, [][]string{{"1"}}); err == nil {
+		t.Error("Expected an error for a row without the filtered column")
+	}
+}
+
+func TestFilterPolicyRows(t *testing.T) {
+	e := NewPolicyEvaluator(pdpEvalPolicies, 3, []int{7})
+	visible, err := FilterPolicyRows(e, []pdpRow{{"East", 500}, {"East", 5}, {"West", 500}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visible) != 1 || visible[0].Amount != 500 || visible[0].Region != "East" {
+		t.Errorf("Unexpected visible rows %+v", visible)
+	}
+}
+
+func TestDatasetsService_PolicyEvaluator(t *testing.T) {
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/datasets/abc/policies":
+			w.Write([]byte(`[{"id": 1, "name": "East", "type": "user", "groups": [7, 8],
+				"filters": [{"column": "region", "operator": "EQUALS", "values": ["East"]}]}]`))
+		case "/v1/groups/7/users":
+			w.Write([]byte(`[1, 2]`))
+		case "/v1/groups/8/users":
+			w.Write([]byte(`[3]`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer server.Close()
+
+	e, err := client.Datasets.PolicyEvaluator(context.Background(), "abc", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.GroupIDs) != 1 || e.GroupIDs[0] != 8 || len(e.Policies) != 1 {
+		t.Errorf("Unexpected evaluator %+v", e)
+	}
+}
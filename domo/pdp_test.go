@@ -0,0 +1,127 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+const pdpTestPolicies = `[
+	{"id": 1, "type": "system", "name": "All Rows", "filters": [], "users": [], "groups": []},
+	{"id": 2, "type": "user", "name": "West", "users": [27, 12],
+		"filters": [{"column": "region", "operator": "EQUALS", "values": ["West", "Pacific"], "not": false}]},
+	{"id": 3, "type": "user", "name": "East", "users": [12],
+		"filters": [{"column": "region", "operator": "EQUALS", "values": ["East"], "not": false}]},
+	{"id": 4, "type": "user", "name": "Old", "groups": [7],
+		"filters": [{"column": "region", "operator": "EQUALS", "values": ["North"], "not": false}]}
+]`
+
+func TestDatasetsService_ListPolicies(t *testing.T) {
+	client, server := testClientStringV2(http.StatusOK, pdpTestPolicies)
+	defer server.Close()
+
+	policies, _, err := client.Datasets.ListPolicies(context.Background(), "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 4 {
+		t.Fatalf("Expected 4 policies, got %d", len(policies))
+	}
+	p := policies[1]
+	if p.ID != 2 || p.PolicyType != PolicyTypeUser || len(p.UserIDs) != 2 || p.Filters[0].Operator != FilterOperatorEquals {
+		t.Errorf("Unexpected policy %+v", p)
+	}
+}
+
+func TestDatasetsService_ReconcilePolicies(t *testing.T) {
+	var requests []string
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(pdpTestPolicies))
+		case "POST", "PUT":
+			var p Policy
+			json.NewDecoder(r.Body).Decode(&p)
+			if p.ID == 0 {
+				p.ID = 5
+			}
+			json.NewEncoder(w).Encode(p)
+		}
+	})
+	defer server.Close()
+	ctx := context.Background()
+
+	desired := []Policy{
+		// the same as the existing policy, in a different order.
+		{Name: "West", PolicyType: PolicyTypeUser, UserIDs: []int{12, 27},
+			Filters: []Filter{{Column: "region", Operator: FilterOperatorEquals, Values: []string{"Pacific", "West"}}}},
+		{Name: "East", PolicyType: PolicyTypeUser, UserIDs: []int{12, 30},
+			Filters: []Filter{{Column: "region", Operator: FilterOperatorEquals, Values: []string{"East"}}}},
+		{Name: "South", PolicyType: PolicyTypeUser, GroupIDs: []int{8},
+			Filters: []Filter{{Column: "region", Operator: FilterOperatorEquals, Values: []string{"South"}}}},
+	}
+
+	result, err := client.Datasets.ReconcilePolicies(ctx, "abc", desired, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 {
+		t.Errorf("Expected a dry run to only list the policies, got %v", requests)
+	}
+	if result.Unchanged != 1 || len(result.Updated) != 1 || len(result.Created) != 1 || len(result.Deleted) != 1 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	if result.Updated[0].ID != 3 || result.Deleted[0].ID != 4 || result.Created[0].Name != "South" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	requests = nil
+	result, err = client.Datasets.ReconcilePolicies(ctx, "abc", desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"GET /v1/datasets/abc/policies",
+		"PUT /v1/datasets/abc/policies/3",
+		"POST /v1/datasets/abc/policies",
+		"DELETE /v1/datasets/abc/policies/4",
+	}
+	if len(requests) != len(expected) {
+		t.Fatalf("Expected requests %v, got %v", expected, requests)
+	}
+	for i := range expected {
+		if requests[i] != expected[i] {
+			t.Errorf("Expected request %s, got %s", expected[i], requests[i])
+		}
+	}
+	if result.Created[0].ID != 5 {
+		t.Errorf("Expected the created policy to be returned, got %+v", result.Created[0])
+	}
+
+	if _, err := client.Datasets.ReconcilePolicies(ctx, "abc", []Policy{{Name: "A"}, {Name: "A"}}, true); err == nil {
+		t.Error("Expected an error for duplicate policy names")
+	}
+}
+
+func TestDatasetsService_ReconcilePoliciesDuplicateNames(t *testing.T) {
+	client, server := testClientStringV2(http.StatusOK, `[
+		{"id": 1, "type": "user", "name": "West", "users": [1]},
+		{"id": 2, "type": "user", "name": "West", "users": [27]},
+		{"id": 3, "type": "user", "name": "West", "users": [3]}
+	]`)
+	defer server.Close()
+
+	desired := []Policy{{Name: "West", PolicyType: PolicyTypeUser, UserIDs: []int{27}}}
+	result, err := client.Datasets.ReconcilePolicies(context.Background(), "abc", desired, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unchanged != 1 || len(result.Updated) != 0 {
+		t.Errorf("Expected the matching policy to be kept, got %+v", result)
+	}
+	if len(result.Deleted) != 2 || result.Deleted[0].ID != 1 || result.Deleted[1].ID != 3 {
+		t.Errorf("Expected the other policies named West to be deleted, got %+v", result.Deleted)
+	}
+}
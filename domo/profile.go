@@ -0,0 +1,485 @@
+package domo
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profile methods.
+const (
+	// ProfileMethodLocal profiles are computed from the streamed CSV export.
+	ProfileMethodLocal = "local"
+	// ProfileMethodSQL profiles are computed by Domo with aggregate queries.
+	ProfileMethodSQL = "sql"
+)
+
+// ProfileOptions configures how datasets are profiled.
+type ProfileOptions struct {
+	// TopK is the number of most common values reported per column. Defaults to 10, negative skips them.
+	TopK int
+	// ExactDistinctLimit is the number of distinct values per column counted exactly by a local profile.
+	// Past it the distinct count is estimated with HyperLogLog and the top values are approximate.
+	// Defaults to 100000.
+	ExactDistinctLimit int
+}
+
+func (o ProfileOptions) withDefaults() ProfileOptions {
+	if o.TopK == 0 {
+		o.TopK = 10
+	}
+	if o.ExactDistinctLimit <= 0 {
+		o.ExactDistinctLimit = 100000
+	}
+	return o
+}
+
+// DatasetProfile holds the column statistics of a dataset.
+type DatasetProfile struct {
+	DatasetID  string          `json:"datasetId,omitempty"`
+	Method     string          `json:"method"`
+	ProfiledAt time.Time       `json:"profiledAt"`
+	Rows       int             `json:"rows"`
+	Columns    []ColumnProfile `json:"columns"`
+}
+
+// ColumnProfile holds the statistics of a single column. Min and Max are compared by the column's
+// type. Mean and StdDev, the population standard deviation, are only set for numeric columns,
+// Earliest and Latest only for DATE and DATETIME columns.
+type ColumnProfile struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Nulls counts the null cells. Local profiles count empty cells as nulls.
+	Nulls          int          `json:"nulls"`
+	Distinct       int          `json:"distinct"`
+	DistinctApprox bool         `json:"distinctApprox,omitempty"`
+	Min            string       `json:"min,omitempty"`
+	Max            string       `json:"max,omitempty"`
+	Mean           *float64     `json:"mean,omitempty"`
+	StdDev         *float64     `json:"stddev,omitempty"`
+	Earliest       *time.Time   `json:"earliest,omitempty"`
+	Latest         *time.Time   `json:"latest,omitempty"`
+	TopValues      []ValueCount `json:"topValues,omitempty"`
+	// TopApprox is set when the top value counts are estimates.
+	TopApprox bool `json:"topApprox,omitempty"`
+	// Invalid counts the cells of a local profile that couldn't be parsed as the column type.
+	Invalid int `json:"invalid,omitempty"`
+}
+
+// ValueCount is a value and the number of rows it's in.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Column returns the profile of the named column, or nil.
+func (p *DatasetProfile) Column(name string) *ColumnProfile {
+	for i := range p.Columns {
+		if p.Columns[i].Name == name {
+			return &p.Columns[i]
+		}
+	}
+	return nil
+}
+
+// JSON encodes the profile as indented JSON.
+func (p *DatasetProfile) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// Profile streams the dataset's CSV export and computes its column statistics locally, without
+// holding the dataset in memory.
+func (s *DatasetsService) Profile(ctx context.Context, id string, opts ProfileOptions) (*DatasetProfile, error) {
+	ds, _, err := s.Info(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := s.ExportDataCSV(ctx, id, true, pw)
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+	profile, err := ProfileCSV(pr, ds.Schema, opts)
+	if err != nil {
+		return nil, err
+	}
+	profile.DatasetID = id
+	return profile, nil
+}
+
+// ProfileCSV computes the column statistics of CSV with a header row. Columns are typed by the
+// schema column of the same name, columns missing from the schema are profiled as STRING.
+func ProfileCSV(r io.Reader, schema Schema, opts ProfileOptions) (*DatasetProfile, error) {
+	opts = opts.withDefaults()
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("CSV has no header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]string, len(schema.Columns))
+	for _, c := range schema.Columns {
+		types[c.Name] = c.ColumnType
+	}
+	profilers := make([]*columnProfiler, len(header))
+	for i, h := range header {
+		columnType := types[h]
+		if columnType == "" {
+			columnType = ColumnTypeString
+		}
+		profilers[i] = newColumnProfiler(h, columnType, opts)
+	}
+
+	profile := &DatasetProfile{Method: ProfileMethodLocal, ProfiledAt: time.Now().UTC()}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		profile.Rows++
+		for i, p := range profilers {
+			cell := ""
+			if i < len(record) {
+				cell = record[i]
+			}
+			p.add(cell)
+		}
+	}
+	for _, p := range profilers {
+		profile.Columns = append(profile.Columns, p.profile())
+	}
+	return profile, nil
+}
+
+// columnProfiler accumulates the statistics of a column one cell at a time.
+type columnProfiler struct {
+	opts ProfileOptions
+	col  ColumnProfile
+
+	min, max string
+	// Welford's running mean and sum of squared differences.
+	n        int
+	mean, m2 float64
+
+	counts map[string]int
+	hll    *hyperLogLog
+	// top holds the space saving counters once counts grows past ExactDistinctLimit.
+	top map[string]int
+}
+
+func newColumnProfiler(name, columnType string, opts ProfileOptions) *columnProfiler {
+	return &columnProfiler{
+		opts:   opts,
+		col:    ColumnProfile{Name: name, Type: columnType},
+		counts: make(map[string]int),
+		hll:    newHyperLogLog(),
+	}
+}
+
+func (p *columnProfiler) add(cell string) {
+	if cell == "" {
+		p.col.Nulls++
+		return
+	}
+	switch p.col.Type {
+	case ColumnTypeLong, ColumnTypeDouble, ColumnTypeDecimal:
+		f, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			p.col.Invalid++
+			return
+		}
+		p.n++
+		delta := f - p.mean
+		p.mean += delta / float64(p.n)
+		p.m2 += delta * (f - p.mean)
+	case ColumnTypeDate, ColumnTypeDatetime:
+		t, err := parseCSVTime(cell)
+		if err != nil {
+			p.col.Invalid++
+			return
+		}
+		if p.col.Earliest == nil || t.Before(*p.col.Earliest) {
+			p.col.Earliest = &t
+		}
+		if p.col.Latest == nil || t.After(*p.col.Latest) {
+			p.col.Latest = &t
+		}
+	}
+	if c, _ := compareCells(p.col.Type, cell, p.min); p.min == "" || c < 0 {
+		p.min = cell
+	}
+	if c, _ := compareCells(p.col.Type, cell, p.max); p.max == "" || c > 0 {
+		p.max = cell
+	}
+
+	p.hll.add(cell)
+	if p.counts != nil {
+		p.counts[cell]++
+		if len(p.counts) > p.opts.ExactDistinctLimit {
+			p.startApproximating()
+		}
+		return
+	}
+	p.countApprox(cell)
+}
+
+// startApproximating drops the exact counts for a space saving sketch of the most common values.
+func (p *columnProfiler) startApproximating() {
+	p.top = make(map[string]int)
+	for _, vc := range topValues(p.counts, p.topCapacity()) {
+		p.top[vc.Value] = vc.Count
+	}
+	p.counts = nil
+	p.col.DistinctApprox = true
+	p.col.TopApprox = true
+}
+
+func (p *columnProfiler) topCapacity() int {
+	if p.opts.TopK <= 0 {
+		return 0
+	}
+	if c := p.opts.TopK * 100; c > 1000 {
+		return c
+	}
+	return 1000
+}
+
+// countApprox counts a value with the space saving algorithm: a new value replaces the least common
+// one and inherits its count.
+func (p *columnProfiler) countApprox(cell string) {
+	if p.opts.TopK <= 0 {
+		return
+	}
+	if _, ok := p.top[cell]; ok || len(p.top) < p.topCapacity() {
+		p.top[cell]++
+		return
+	}
+	minValue, minCount := "", math.MaxInt64
+	for v, c := range p.top {
+		if c < minCount {
+			minValue, minCount = v, c
+		}
+	}
+	delete(p.top, minValue)
+	p.top[cell] = minCount + 1
+}
+
+func (p *columnProfiler) profile() ColumnProfile {
+	col := p.col
+	col.Min, col.Max = p.min, p.max
+	if p.n > 0 {
+		mean, stddev := p.mean, math.Sqrt(p.m2/float64(p.n))
+		col.Mean, col.StdDev = &mean, &stddev
+	}
+	if p.counts != nil {
+		col.Distinct = len(p.counts)
+		if p.opts.TopK > 0 {
+			col.TopValues = topValues(p.counts, p.opts.TopK)
+		}
+	} else {
+		col.Distinct = p.hll.count()
+		if p.opts.TopK > 0 {
+			col.TopValues = topValues(p.top, p.opts.TopK)
+		}
+	}
+	return col
+}
+
+// topValues returns the k most common values, ties broken by value.
+func topValues(counts map[string]int, k int) []ValueCount {
+	values := make([]ValueCount, 0, len(counts))
+	for v, c := range counts {
+		values = append(values, ValueCount{Value: v, Count: c})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > k {
+		values = values[:k]
+	}
+	return values
+}
+
+// hyperLogLog estimates the number of distinct values added to it in fixed memory.
+type hyperLogLog struct {
+	registers []uint8
+}
+
+// hllPrecision bits of each hash pick the register, giving an error of about 0.8%.
+const hllPrecision = 14
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1<<hllPrecision)}
+}
+
+func (h *hyperLogLog) add(value string) {
+	f := fnv.New64a()
+	f.Write([]byte(value))
+	x := mix64(f.Sum64())
+	i := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+func (h *hyperLogLog) count() int {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Pow(2, -float64(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// linear counting is more accurate for small counts.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(estimate + 0.5)
+}
+
+// mix64 spreads the bits of an fnv hash, which are poorly distributed for short values.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// ProfileSQL computes the dataset's column statistics with aggregate queries run by Domo, so no data is
+// downloaded. It runs one query for the aggregates and, unless TopK is negative, one per column for the
+// top values. Only nulls are counted as nulls and ExactDistinctLimit is ignored, Domo counts distinct
+// values exactly. StdDev is Domo's STDDEV_POP, which doesn't lose precision on large values the way
+// deriving it from the mean of the squares does.
+func (s *DatasetsService) ProfileSQL(ctx context.Context, id string, opts ProfileOptions) (*DatasetProfile, error) {
+	opts = opts.withDefaults()
+	ds, _, err := s.Info(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	columns := ds.Schema.Columns
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("dataset %s has no columns", id)
+	}
+
+	selects := []string{"COUNT(*)"}
+	for _, c := range columns {
+		q := quoteSQLColumn(c.Name)
+		selects = append(selects, "COUNT("+q+")", "COUNT(DISTINCT "+q+")", "MIN("+q+")", "MAX("+q+")")
+		if isNumericColumn(c.ColumnType) {
+			selects = append(selects, "AVG("+q+")", "STDDEV_POP("+q+")")
+		}
+	}
+	result, _, err := s.Query(ctx, id, "SELECT "+strings.Join(selects, ", ")+" FROM table")
+	if err != nil {
+		return nil, err
+	}
+	rows := result.StringRows()
+	if len(rows) != 1 || len(rows[0]) != len(selects) {
+		return nil, fmt.Errorf("expected 1 row of %d aggregates for dataset %s", len(selects), id)
+	}
+	values := rows[0]
+	next := func() string {
+		v := values[0]
+		values = values[1:]
+		return v
+	}
+	toInt := func(v string) (int, error) {
+		f, err := strconv.ParseFloat(v, 64)
+		return int(f), err
+	}
+
+	profile := &DatasetProfile{DatasetID: id, Method: ProfileMethodSQL, ProfiledAt: time.Now().UTC()}
+	if profile.Rows, err = toInt(next()); err != nil {
+		return nil, fmt.Errorf("row count: %v", err)
+	}
+	for _, c := range columns {
+		col := ColumnProfile{Name: c.Name, Type: c.ColumnType}
+		nonNull, err := toInt(next())
+		if err != nil {
+			return nil, fmt.Errorf("column %q count: %v", c.Name, err)
+		}
+		col.Nulls = profile.Rows - nonNull
+		if col.Distinct, err = toInt(next()); err != nil {
+			return nil, fmt.Errorf("column %q distinct count: %v", c.Name, err)
+		}
+		col.Min, col.Max = next(), next()
+		if isNumericColumn(c.ColumnType) {
+			avg, stddev := next(), next()
+			if mean, err := strconv.ParseFloat(avg, 64); err == nil {
+				col.Mean = &mean
+			}
+			if stddev, err := strconv.ParseFloat(stddev, 64); err == nil {
+				col.StdDev = &stddev
+			}
+		}
+		if c.ColumnType == ColumnTypeDate || c.ColumnType == ColumnTypeDatetime {
+			if t, err := parseCSVTime(col.Min); err == nil {
+				col.Earliest = &t
+			}
+			if t, err := parseCSVTime(col.Max); err == nil {
+				col.Latest = &t
+			}
+		}
+		if opts.TopK > 0 {
+			if col.TopValues, err = s.topValuesSQL(ctx, id, c.Name, opts.TopK); err != nil {
+				return nil, err
+			}
+		}
+		profile.Columns = append(profile.Columns, col)
+	}
+	return profile, nil
+}
+
+func (s *DatasetsService) topValuesSQL(ctx context.Context, id, column string, k int) ([]ValueCount, error) {
+	q := quoteSQLColumn(column)
+	sql := fmt.Sprintf("SELECT %s, COUNT(*) AS n FROM table WHERE %s IS NOT NULL GROUP BY %s ORDER BY n DESC, %s LIMIT %d", q, q, q, q, k)
+	result, _, err := s.Query(ctx, id, sql)
+	if err != nil {
+		return nil, fmt.Errorf("column %q top values: %v", column, err)
+	}
+	var values []ValueCount
+	for _, row := range result.StringRows() {
+		if len(row) != 2 {
+			return nil, fmt.Errorf("column %q top values: expected 2 columns, got %d", column, len(row))
+		}
+		count, err := strconv.ParseFloat(row[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("column %q top values: %v", column, err)
+		}
+		values = append(values, ValueCount{Value: row[0], Count: int(count)})
+	}
+	return values, nil
+}
+
+func quoteSQLColumn(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func isNumericColumn(columnType string) bool {
+	return columnType == ColumnTypeLong || columnType == ColumnTypeDouble || columnType == ColumnTypeDecimal
+}
@@ -0,0 +1,172 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var profileTestSchema = Schema{Columns: []Column{
+	{ColumnType: ColumnTypeString, Name: "region"},
+	{ColumnType: ColumnTypeLong, Name: "amount"},
+	{ColumnType: ColumnTypeDate, Name: "day"},
+}}
+
+func TestProfileCSV(t *testing.T) {
+	data := "region,amount,day,extra\n" +
+		"West,10,2019-03-04,a\n" +
+		"East,2,2019-01-31,b\n" +
+		"West,x,,c\n" +
+		",4,2019-12-01,d\n"
+	profile, err := ProfileCSV(strings.NewReader(data), profileTestSchema, ProfileOptions{TopK: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Rows != 4 || profile.Method != ProfileMethodLocal || len(profile.Columns) != 4 {
+		t.Fatalf("Unexpected profile %+v", profile)
+	}
+
+	region := profile.Column("region")
+	if region.Nulls != 1 || region.Distinct != 2 || region.Min != "East" || region.Max != "West" {
+		t.Errorf("Unexpected region profile %+v", region)
+	}
+	if len(region.TopValues) != 1 || region.TopValues[0] != (ValueCount{Value: "West", Count: 2}) {
+		t.Errorf("Unexpected top values %+v", region.TopValues)
+	}
+
+	amount := profile.Column("amount")
+	// 10 sorts before 2 as a string, but not as a LONG.
+	if amount.Min != "2" || amount.Max != "10" || amount.Invalid != 1 || *amount.Mean != 16.0/3 {
+		t.Errorf("Unexpected amount profile %+v", amount)
+	}
+	if math.Abs(*amount.StdDev-3.399346) > 0.0001 {
+		t.Errorf("Expected a standard deviation of 3.399346, got %f", *amount.StdDev)
+	}
+
+	day := profile.Column("day")
+	if day.Nulls != 1 || day.Earliest.Format(csvDateLayout) != "2019-01-31" || day.Latest.Format(csvDateLayout) != "2019-12-01" {
+		t.Errorf("Unexpected day profile %+v", day)
+	}
+	if extra := profile.Column("extra"); extra == nil || extra.Type != ColumnTypeString || extra.Distinct != 4 {
+		t.Errorf("Expected the column missing from the schema to be profiled as a STRING, got %+v", extra)
+	}
+
+	b, err := profile.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded DatasetProfile
+	if err := json.Unmarshal(b, &decoded); err != nil || decoded.Column("amount").Invalid != 1 {
+		t.Errorf("Expected the profile to round trip through JSON, got %v %s", err, b)
+	}
+}
+
+func TestProfileCSV_Approximate(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("id\n")
+	const distinct = 50000
+	for i := 0; i < distinct; i++ {
+		b.WriteString(strconv.Itoa(i) + "\n")
+		if i%10 == 0 {
+			b.WriteString("hot\n")
+		}
+	}
+	profile, err := ProfileCSV(strings.NewReader(b.String()), Schema{}, ProfileOptions{TopK: 1, ExactDistinctLimit: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := profile.Column("id")
+	if !id.DistinctApprox || !id.TopApprox {
+		t.Errorf("Expected approximate counts past the exact limit, got %+v", id)
+	}
+	if e := math.Abs(float64(id.Distinct-distinct-1)) / distinct; e > 0.03 {
+		t.Errorf("Expected about %d distinct values, got %d", distinct+1, id.Distinct)
+	}
+	if len(id.TopValues) != 1 || id.TopValues[0].Value != "hot" || id.TopValues[0].Count < distinct/10 {
+		t.Errorf("Expected hot to be the top value, got %+v", id.TopValues)
+	}
+}
+
+func TestDatasetsService_ProfileSQL(t *testing.T) {
+	var queries []string
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/datasets/abc":
+			json.NewEncoder(w).Encode(Dataset{ID: "abc", Schema: profileTestSchema})
+		case "/v1/datasets/query/execute/abc":
+			var body struct {
+				SQL string `json:"sql"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			queries = append(queries, body.SQL)
+			switch {
+			case strings.HasPrefix(body.SQL, "SELECT COUNT(*)"):
+				w.Write([]byte(`{"columns": [], "rows": [[10, 9, 3, "East", "West", 10, 5, 2, 9, 4.5, 2.5, 9, 8, "2019-01-31", "2019-12-01"]]}`))
+			default:
+				w.Write([]byte(`{"columns": ["v", "n"], "rows": [["West", 6], ["East", 3]]}`))
+			}
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer server.Close()
+
+	profile, err := client.Datasets.ProfileSQL(context.Background(), "abc", ProfileOptions{TopK: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "SELECT COUNT(*), COUNT(`region`), COUNT(DISTINCT `region`), MIN(`region`), MAX(`region`), " +
+		"COUNT(`amount`), COUNT(DISTINCT `amount`), MIN(`amount`), MAX(`amount`), AVG(`amount`), STDDEV_POP(`amount`), " +
+		"COUNT(`day`), COUNT(DISTINCT `day`), MIN(`day`), MAX(`day`) FROM table"
+	if len(queries) != 4 || queries[0] != expected {
+		t.Fatalf("Unexpected queries %q", queries)
+	}
+	if profile.Rows != 10 || profile.Method != ProfileMethodSQL {
+		t.Errorf("Unexpected profile %+v", profile)
+	}
+	region := profile.Column("region")
+	if region.Nulls != 1 || region.Distinct != 3 || region.TopValues[0] != (ValueCount{Value: "West", Count: 6}) {
+		t.Errorf("Unexpected region profile %+v", region)
+	}
+	amount := profile.Column("amount")
+	if *amount.Mean != 4.5 || *amount.StdDev != 2.5 || amount.Min != "2" {
+		t.Errorf("Unexpected amount profile %+v", amount)
+	}
+	if day := profile.Column("day"); day.Latest == nil || day.Latest.Format(csvDateLayout) != "2019-12-01" {
+		t.Errorf("Unexpected day profile %+v", day)
+	}
+}
+
+func TestDatasetsService_ProfileSQLLargeValues(t *testing.T) {
+	var query string
+	client, server := testClientRoutesV2(t,
+		testRoute{"GET /v1/datasets/abc", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(Dataset{ID: "abc", Schema: Schema{Columns: []Column{{ColumnType: ColumnTypeLong, Name: "id"}}}})
+		}},
+		testRoute{"POST /v1/datasets/query/execute/abc", func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				SQL string `json:"sql"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			query = body.SQL
+			// ids 4000000000000000001 and 4000000000000000002, whose squares overflow a LONG.
+			w.Write([]byte(`{"columns": [], "rows": [[2, 2, 2, "4000000000000000001", "4000000000000000002", 4.0000000000000005e18, 0.5]]}`))
+		}},
+	)
+	defer server.Close()
+
+	profile, err := client.Datasets.ProfileSQL(context.Background(), "abc", ProfileOptions{TopK: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(query, "STDDEV_POP(`id`)") || strings.Contains(query, "`id` * `id`") {
+		t.Errorf("Expected Domo to compute the standard deviation, got %q", query)
+	}
+	if id := profile.Column("id"); id.StdDev == nil || *id.StdDev != 0.5 {
+		t.Errorf("Unexpected id profile %+v", id)
+	}
+}
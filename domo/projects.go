@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"time"
 )
 
 type Project struct {
@@ -12,10 +11,10 @@ type Project struct {
 	Name string `json:"name,omitempty"`
 	MemberIDs []int `json:"members,omitempty"`
 	CreatedByUserID int `json:"createdBy,omitempty"`
-	CreatedDate time.Time `json:"createdDate,omitempty"`
+	CreatedDate *Timestamp `json:"createdDate,omitempty"`
 	Public bool `json:"public,omitempty"`
-	Description string `json:"description, omitempty"`
-	DueDate time.Time `json:"dueDate, omitempty"`
+	Description string `json:"description,omitempty"`
+	DueDate *Timestamp `json:"dueDate,omitempty"`
 }
 // ProjectsService handles communication with the projects
 // related methods of the Domo API.
@@ -105,8 +104,8 @@ func (s *ProjectsService) Update(ctx context.Context, project Project) (*Project
 	updateBody := struct {
 		Name string `json:"name,omitempty"`
 		Public bool `json:"public,omitempty"`
-		Description string `json:"description, omitempty"`
-		DueDate time.Time `json:"dueDate, omitempty"`
+		Description string `json:"description,omitempty"`
+		DueDate *Timestamp `json:"dueDate,omitempty"`
 	}{project.Name, project.Public, project.Description, project.DueDate}
 	req, err := s.client.NewRequest("PUT", u, updateBody)
 	if err != nil {
@@ -1,7 +1,9 @@
 package domo
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -9,23 +11,59 @@ import (
 
 // TagSeparator defines seperator string for multiple domo tags in struct fields
 var TagSeparator = ","
+
+// ExplodeSeparator joins an exploded collection field's column name and the
+// element index. i.e. `domo:"tags,explode=2"` creates the columns tags_0 and tags_1.
+var ExplodeSeparator = "_"
+
+// Domo struct tag options. Anything in a domo tag that isn't an option or a
+// column type is treated as a column name.
+const (
+	// tagOptionOmitEmpty writes an empty cell for zero values.
+	tagOptionOmitEmpty = "omitempty"
+	// tagOptionJSON serializes the field as a JSON document in a single STRING column.
+	// It's the default for slices, maps and arrays. Nested structs are flattened into
+	// their fields' columns unless tagged json.
+	tagOptionJSON = "json"
+	// tagOptionExplode splits an array or slice into one column per element.
+	// Slices need a fixed element count, i.e. `domo:"tags,explode=3"`.
+	tagOptionExplode = "explode"
+	// tagOptionPrefix prefixes the column names of a nested or embedded struct's
+	// fields, i.e. `domo:"addr,prefix=addr_"`.
+	tagOptionPrefix = "prefix"
+	// tagOptionKey marks the field as one of the key columns identifying a row, i.e. `domo:"id,key"`.
+	tagOptionKey = "key"
+)
+
 // Normalizer is a fn that takes and returns a string. It is applied to struct
 // and header field values before compare. It can be used to alter names for comparision.
 type Normalizer func(string) string
+
 var normalizeName = DefaultNameNormalizer()
+
 // DefaultNameNormalizer nop Normalizer
-func DefaultNameNormalizer() Normalizer { return func(s string) string { return s} }
+func DefaultNameNormalizer() Normalizer { return func(s string) string { return s } }
+
 // SetNormalizer sets the normalizer used to normalize struct/header field names.
-func SetNormalizer(f Normalizer) { normalizeName = f}
+func SetNormalizer(f Normalizer) { normalizeName = f }
+
 type structInfo struct {
 	Fields []fieldInfo
 }
 
 type fieldInfo struct {
-	keys []string
-	omitEmpty bool
-	IndexChain []int
+	keys           []string
+	omitEmpty      bool
+	IndexChain     []int
 	DomoColumnType string
+	// path is the Go field path, i.e. Home.Address.Street. Used for error messages.
+	path string
+	// encodeJSON marks a field serialized as a JSON document in a single cell.
+	encodeJSON bool
+	// elemIndex is the element of an exploded array or slice this column holds, -1 otherwise.
+	elemIndex int
+	// key marks a key column.
+	key bool
 }
 
 func (f fieldInfo) getFirstKey() string {
@@ -41,22 +79,132 @@ func (f fieldInfo) matchesKey(key string) bool {
 	return false
 }
 
+// DuplicateColumnError is returned by schema generation when two struct fields
+// map to the same Domo column name. Use the prefix tag option or rename one of the
+// columns to resolve it.
+type DuplicateColumnError struct {
+	Column string
+	Fields []string
+}
+
+func (e DuplicateColumnError) Error() string {
+	return fmt.Sprintf("duplicate column %q generated by fields %s", e.Column, strings.Join(e.Fields, " and "))
+}
+
 var structMap = make(map[reflect.Type]*structInfo)
 var structMapMutext sync.RWMutex
 
-func getStructInfo(rType reflect.Type) *structInfo {
+var timeType = reflect.TypeOf(time.Time{})
+
+func getStructInfo(rType reflect.Type) (*structInfo, error) {
+	if rType.Kind() == reflect.Ptr {
+		rType = rType.Elem()
+	}
+	if rType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct type but got %s", rType)
+	}
 	structMapMutext.RLock()
 	stInfo, ok := structMap[rType]
 	structMapMutext.RUnlock()
 	if ok {
-		return stInfo
+		return stInfo, nil
+	}
+	fieldsList, err := getFieldInfos(rType, []int{}, "", "")
+	if err != nil {
+		return nil, err
+	}
+	columnFields := make(map[string]string, len(fieldsList))
+	for _, f := range fieldsList {
+		col := f.getFirstKey()
+		if other, ok := columnFields[col]; ok {
+			return nil, DuplicateColumnError{Column: col, Fields: []string{other, f.path}}
+		}
+		columnFields[col] = f.path
 	}
-	fieldsList := getFieldInfos(rType, []int{})
 	stInfo = &structInfo{fieldsList}
-	return stInfo
+	return stInfo, nil
+}
+
+// fieldTag is a parsed domo struct field tag.
+type fieldTag struct {
+	names      []string
+	columnType string
+	omitEmpty  bool
+	json       bool
+	explode    bool
+	explodeLen int
+	prefix     string
+	key        bool
+}
+
+func parseFieldTag(tag string) (fieldTag, error) {
+	var ft fieldTag
+	for _, entry := range strings.Split(tag, TagSeparator) {
+		opt := strings.TrimSpace(entry)
+		switch {
+		case opt == tagOptionOmitEmpty:
+			ft.omitEmpty = true
+		case opt == tagOptionJSON:
+			ft.json = true
+		case opt == tagOptionKey:
+			ft.key = true
+		case opt == tagOptionExplode:
+			ft.explode = true
+		case strings.HasPrefix(opt, tagOptionExplode+"="):
+			n, err := strconv.Atoi(strings.TrimPrefix(opt, tagOptionExplode+"="))
+			if err != nil || n < 1 {
+				return ft, fmt.Errorf("invalid explode length in tag option %q", opt)
+			}
+			ft.explode = true
+			ft.explodeLen = n
+		case strings.HasPrefix(opt, tagOptionPrefix+"="):
+			ft.prefix = strings.TrimPrefix(opt, tagOptionPrefix+"=")
+		case opt == ColumnTypeString, opt == ColumnTypeLong, opt == ColumnTypeDouble, opt == ColumnTypeDecimal, opt == ColumnTypeDate, opt == ColumnTypeDatetime:
+			// Overwrite default value of DomoColumnType with Tag specified value
+			ft.columnType = opt
+		default:
+			ft.names = append(ft.names, normalizeName(entry))
+		}
+	}
+	if ft.json && ft.explode {
+		return ft, fmt.Errorf("tag options %s and %s can't be used together", tagOptionJSON, tagOptionExplode)
+	}
+	return ft, nil
+}
+
+// defaultColumnType is the Domo column type used for a go type when the tag doesn't specify one.
+func defaultColumnType(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return ColumnTypeDatetime
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return ColumnTypeLong
+	case reflect.Float32, reflect.Float64:
+		return ColumnTypeDouble
+	default:
+		return ColumnTypeString
+	}
+}
+
+// needsJSONEncoding reports whether values of the type have no single cell representation of their own.
+func needsJSONEncoding(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Interface:
+		return true
+	case reflect.Struct:
+		return t != timeType
+	}
+	return false
 }
 
-func getFieldInfos(rType reflect.Type, parentIndexChain []int) []fieldInfo {
+func getFieldInfos(rType reflect.Type, parentIndexChain []int, prefix, parentPath string) ([]fieldInfo, error) {
 	fieldsCount := rType.NumField()
 	fieldsList := make([]fieldInfo, 0, fieldsCount)
 	for i := 0; i < fieldsCount; i++ {
@@ -69,81 +217,153 @@ func getFieldInfos(rType reflect.Type, parentIndexChain []int) []fieldInfo {
 		copy(cpy, parentIndexChain)
 		indexChain := append(cpy, i)
 
-		// if the field is a pointer to a struct, follow it and create fieldInfo for each field
-		if field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct {
-			fieldsList = append(fieldsList, getFieldInfos(field.Type.Elem(), indexChain)...)
+		path := field.Name
+		if parentPath != "" {
+			path = parentPath + "." + field.Name
+		}
+		tag, err := parseFieldTag(field.Tag.Get("domo"))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", path, err)
 		}
 
-		// if the field is a struct, create fieldInfo for each field
-		if field.Type.Kind() == reflect.Struct {
-			fieldsList = append(fieldsList, getFieldInfos(field.Type, indexChain)...)
+		fType := field.Type
+		if fType.Kind() == reflect.Ptr {
+			fType = fType.Elem()
 		}
 
-		// if the field is an embedded struct, ignore the domo tag
-		if field.Anonymous {
+		// if the field is a struct or a pointer to a struct, create fieldInfo for each field instead of
+		// a column of its own, unless it's tagged json to be encoded into a single cell.
+		if fType.Kind() == reflect.Struct && fType != timeType && !tag.json {
+			nested, err := getFieldInfos(fType, indexChain, prefix+tag.prefix, path)
+			if err != nil {
+				return nil, err
+			}
+			fieldsList = append(fieldsList, nested...)
 			continue
 		}
-		var v reflect.Kind
-		if field.Type.Kind() == reflect.Ptr {
-			v = field.Type.Elem().Kind()
-		} else {
-			v = field.Type.Kind()
-		}
-		fieldInfo := fieldInfo{IndexChain:indexChain}
-
-		// Set Default Values for DomoColumnType.
-		switch v {
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			fieldInfo.DomoColumnType = ColumnTypeLong
-		case reflect.Float32, reflect.Float64:
-			fieldInfo.DomoColumnType = ColumnTypeDouble
-		case reflect.String:
-			fieldInfo.DomoColumnType = ColumnTypeString
-		case reflect.Bool:
-			fieldInfo.DomoColumnType = ColumnTypeString
-		default:
-			fieldInfo.DomoColumnType = ColumnTypeString
-		}
-		if field.Type == reflect.TypeOf(time.Time{}) {
-			fieldInfo.DomoColumnType = ColumnTypeDatetime
-		}
-
-		fieldTag := field.Tag.Get("domo")
-		fieldTags := strings.Split(fieldTag, TagSeparator)
-		filteredTags := []string{}
-		for _, fieldTagEntry := range fieldTags {
-			 if fieldTagEntry != "omitempty" {
-				switch fieldTagEntry {
-				case ColumnTypeString, ColumnTypeLong, ColumnTypeDouble, ColumnTypeDecimal, ColumnTypeDate, ColumnTypeDatetime:
-					// Overwrite default value of DomoColumnType with Tag specified value
-					fieldInfo.DomoColumnType = fieldTagEntry
-				default:
-					filteredTags = append(filteredTags, normalizeName(fieldTagEntry))
-				}
-			} else {
-				fieldInfo.omitEmpty = true
-			}
+
+		// an embedded struct's domo tag only sets the prefix of its fields
+		if field.Anonymous && !tag.json {
+			continue
+		}
+
+		if len(tag.names) == 1 && tag.names[0] == "-" {
+			continue
+		}
+		names := tag.names
+		if len(names) == 0 || names[0] == "" {
+			names = []string{normalizeName(field.Name)}
+		}
+		keys := make([]string, len(names))
+		for k, name := range names {
+			keys[k] = prefix + name
 		}
 
-		if len(filteredTags) == 1 && filteredTags[0] == "-" {
+		if tag.explode {
+			exploded, err := explodeFieldInfos(fType, tag, keys, indexChain, path)
+			if err != nil {
+				return nil, err
+			}
+			fieldsList = append(fieldsList, exploded...)
 			continue
-		} else if len(filteredTags) > 0 && filteredTags[0] != "" {
-			fieldInfo.keys = filteredTags
-		} else {
-			fieldInfo.keys = []string{normalizeName(field.Name)}
+		}
+
+		fieldInfo := fieldInfo{
+			keys:           keys,
+			omitEmpty:      tag.omitEmpty,
+			IndexChain:     indexChain,
+			DomoColumnType: defaultColumnType(fType),
+			path:           path,
+			encodeJSON:     tag.json || needsJSONEncoding(fType),
+			elemIndex:      -1,
+			key:            tag.key,
+		}
+		if tag.columnType != "" {
+			fieldInfo.DomoColumnType = tag.columnType
 		}
 		fieldsList = append(fieldsList, fieldInfo)
 	}
-	return fieldsList
+	return fieldsList, nil
+}
+
+// explodeFieldInfos creates a fieldInfo per element of an array or slice field tagged with explode.
+func explodeFieldInfos(fType reflect.Type, tag fieldTag, keys []string, indexChain []int, path string) ([]fieldInfo, error) {
+	n := tag.explodeLen
+	switch fType.Kind() {
+	case reflect.Array:
+		if n == 0 {
+			n = fType.Len()
+		}
+	case reflect.Slice:
+		if n == 0 {
+			return nil, fmt.Errorf("field %s: exploding a slice requires a column count, i.e. %s=3", path, tagOptionExplode)
+		}
+	default:
+		return nil, fmt.Errorf("field %s: %s is only supported for arrays and slices, not %s", path, tagOptionExplode, fType.Kind())
+	}
+
+	elemType := fType.Elem()
+	columnType := defaultColumnType(elemType)
+	if tag.columnType != "" {
+		columnType = tag.columnType
+	}
+	fields := make([]fieldInfo, n)
+	for i := 0; i < n; i++ {
+		elemKeys := make([]string, len(keys))
+		for k, key := range keys {
+			elemKeys[k] = key + ExplodeSeparator + strconv.Itoa(i)
+		}
+		fields[i] = fieldInfo{
+			keys:           elemKeys,
+			omitEmpty:      tag.omitEmpty,
+			IndexChain:     indexChain,
+			DomoColumnType: columnType,
+			path:           fmt.Sprintf("%s[%d]", path, i),
+			encodeJSON:     needsJSONEncoding(elemType),
+			elemIndex:      i,
+			key:            tag.key,
+		}
+	}
+	return fields, nil
+}
+
+// keyFields returns the fields tagged as key columns.
+func (si *structInfo) keyFields() []fieldInfo {
+	var keys []fieldInfo
+	for _, f := range si.Fields {
+		if f.key {
+			keys = append(keys, f)
+		}
+	}
+	return keys
 }
 
-// GenerateDataSEtSchema formatted for Domo from a Struct + Struct Field Tags.
-//
-func GenerateDataSetSchema(rType reflect.Type) Schema {
-	si := getStructInfo(rType)
+// rowKey joins the formatted key column cells of the struct value row.
+func rowKey(keyFields []fieldInfo, row reflect.Value) (string, error) {
+	if !row.IsValid() {
+		return "", fmt.Errorf("row is nil")
+	}
+	cells := make([]string, len(keyFields))
+	for i, f := range keyFields {
+		cell, err := f.formatCell(row)
+		if err != nil {
+			return "", err
+		}
+		cells[i] = cell
+	}
+	return strings.Join(cells, "\x1f"), nil
+}
+
+// GenerateDataSetSchema formatted for Domo from a Struct + Struct Field Tags.
+// It returns a DuplicateColumnError if two fields generate the same column name.
+func GenerateDataSetSchema(rType reflect.Type) (Schema, error) {
+	si, err := getStructInfo(rType)
+	if err != nil {
+		return Schema{}, err
+	}
 	var columns []Column
 	for _, field := range si.Fields {
 		columns = append(columns, Column{ColumnType: field.DomoColumnType, Name: field.getFirstKey()})
 	}
-	return Schema{Columns: columns}
-}
\ No newline at end of file
+	return Schema{Columns: columns}, nil
+}
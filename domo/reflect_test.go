@@ -30,7 +30,10 @@ type DomoEmbeddedSample struct {
 func TestGenerateDataSetSchema(t *testing.T) {
 	expectedColTypes := []string{"STRING","LONG","DOUBLE","LONG","LONG","DOUBLE"}
 	expectedColNames := []string{"Foo","bar","baz","BazBar","obar","obaz"}
-	domoSampleSchema := GenerateDataSetSchema(reflect.TypeOf(DomoSample{}))
+	domoSampleSchema, err := GenerateDataSetSchema(reflect.TypeOf(DomoSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(domoSampleSchema.Columns) != 6 {
 		t.Fatalf("Expected 6 columns but got %d\n%v", len(domoSampleSchema.Columns), domoSampleSchema.Columns)
 	}
@@ -47,7 +50,10 @@ func TestGenerateDataSetSchema(t *testing.T) {
 func TestGenerateDataSetSchema_NestedStruct(t *testing.T) {
 	expectedColTypes := []string{"DECIMAL","DATE","DATETIME","STRING","LONG","DOUBLE","LONG","LONG","DOUBLE"}
 	expectedColNames := []string{"Blah","firstBlahDay","firstBlahTime","Foo","bar","baz","BazBar","obar","obaz"}
-	domoSampleSchema := GenerateDataSetSchema(reflect.TypeOf(DomoNestedSample{}))
+	domoSampleSchema, err := GenerateDataSetSchema(reflect.TypeOf(DomoNestedSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(domoSampleSchema.Columns) != 9 {
 		t.Fatalf("Expected 9 columns but got %d\n%v", len(domoSampleSchema.Columns), domoSampleSchema.Columns)
 	}
@@ -64,7 +70,10 @@ func TestGenerateDataSetSchema_NestedStruct(t *testing.T) {
 func TestGenerateDataSetSchema_EmbedStruct(t *testing.T) {
 	expectedColTypes := []string{"DECIMAL","DATE","DATETIME","STRING","LONG","DOUBLE","LONG","LONG","DOUBLE"}
 	expectedColNames := []string{"Blah","firstBlahDay","firstBlahTime","Foo","bar","baz","BazBar","obar","obaz"}
-	domoSampleSchema := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	domoSampleSchema, err := GenerateDataSetSchema(reflect.TypeOf(DomoEmbeddedSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(domoSampleSchema.Columns) != 9 {
 		t.Fatalf("Expected 9 columns but got %d\n%v", len(domoSampleSchema.Columns), domoSampleSchema.Columns)
 	}
@@ -77,3 +86,90 @@ func TestGenerateDataSetSchema_EmbedStruct(t *testing.T) {
 		}
 	}
 }
+
+type DomoAddress struct {
+	Name   string
+	Street string
+}
+type DomoPrefixedSample struct {
+	Name string
+	Home DomoAddress  `domo:"home,prefix=home_"`
+	Work *DomoAddress `domo:"work,prefix=work_"`
+}
+type DomoCollidingSample struct {
+	Name string
+	Home DomoAddress `domo:"-"`
+}
+type DomoCollectionSample struct {
+	Tags    []string          `domo:"tags"`
+	Attrs   map[string]string `domo:"attrs"`
+	Scores  [2]float64        `domo:"score,explode"`
+	Codes   []int             `domo:"code,explode=3"`
+	Aliases []string          `domo:"aliases,json"`
+	Address *DomoAddress      `domo:"address,json"`
+}
+
+func TestGenerateDataSetSchema_PrefixedNestedStruct(t *testing.T) {
+	expectedColNames := []string{"Name", "home_Name", "home_Street", "work_Name", "work_Street"}
+	schema, err := GenerateDataSetSchema(reflect.TypeOf(DomoPrefixedSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Columns) != len(expectedColNames) {
+		t.Fatalf("Expected %d columns but got %d\n%v", len(expectedColNames), len(schema.Columns), schema.Columns)
+	}
+	for i, col := range schema.Columns {
+		if col.Name != expectedColNames[i] {
+			t.Errorf("Expected column %d to be named %s but got %s", i, expectedColNames[i], col.Name)
+		}
+	}
+}
+
+func TestGenerateDataSetSchema_DuplicateColumn(t *testing.T) {
+	_, err := GenerateDataSetSchema(reflect.TypeOf(DomoCollidingSample{}))
+	dupErr, ok := err.(DuplicateColumnError)
+	if !ok {
+		t.Fatalf("Expected a DuplicateColumnError, got %v", err)
+	}
+	if dupErr.Column != "Name" {
+		t.Errorf("Expected duplicate column Name, got %s", dupErr.Column)
+	}
+	if len(dupErr.Fields) != 2 || dupErr.Fields[0] != "Name" || dupErr.Fields[1] != "Home.Name" {
+		t.Errorf("Expected fields Name and Home.Name, got %v", dupErr.Fields)
+	}
+}
+
+func TestGenerateDataSetSchema_Collections(t *testing.T) {
+	expectedColTypes := []string{"STRING", "STRING", "DOUBLE", "DOUBLE", "LONG", "LONG", "LONG", "STRING", "STRING"}
+	expectedColNames := []string{"tags", "attrs", "score_0", "score_1", "code_0", "code_1", "code_2", "aliases", "address"}
+	schema, err := GenerateDataSetSchema(reflect.TypeOf(DomoCollectionSample{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Columns) != len(expectedColNames) {
+		t.Fatalf("Expected %d columns but got %d\n%v", len(expectedColNames), len(schema.Columns), schema.Columns)
+	}
+	for i, col := range schema.Columns {
+		if col.ColumnType != expectedColTypes[i] {
+			t.Errorf("Expected column %d (%s) to be type %s but got %s", i, col.Name, expectedColTypes[i], col.ColumnType)
+		}
+		if col.Name != expectedColNames[i] {
+			t.Errorf("Expected column %d to be named %s but got %s", i, expectedColNames[i], col.Name)
+		}
+	}
+}
+
+func TestGenerateDataSetSchema_InvalidExplode(t *testing.T) {
+	type unsizedSlice struct {
+		Tags []string `domo:"tags,explode"`
+	}
+	type explodedMap struct {
+		Attrs map[string]string `domo:"attrs,explode=2"`
+	}
+	if _, err := GenerateDataSetSchema(reflect.TypeOf(unsizedSlice{})); err == nil {
+		t.Error("Expected an error exploding a slice without a column count")
+	}
+	if _, err := GenerateDataSetSchema(reflect.TypeOf(explodedMap{})); err == nil {
+		t.Error("Expected an error exploding a map")
+	}
+}
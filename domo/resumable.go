@@ -0,0 +1,262 @@
+package domo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// UploadJournal records the progress of a multi-part stream upload so it can be resumed
+// after the process dies.
+type UploadJournal struct {
+	StreamID    int       `json:"streamId"`
+	ExecutionID int       `json:"executionId"`
+	StartedAt   time.Time `json:"startedAt"`
+	// Parts maps each uploaded part number to the sha256 checksum of its csv.
+	Parts map[int]string `json:"parts"`
+	// Committing is set before the commit is sent. Domo may still report the execution as ACTIVE
+	// while it imports the data, so a committing execution is waited on instead of committed again.
+	Committing bool `json:"committing,omitempty"`
+}
+
+// ResumableUploader uploads csv parts through a stream execution while keeping an UploadJournal
+// per stream in Dir. If a run dies mid-upload, the next Upload for the stream finds the open
+// execution, uploads only the parts that are missing or have changed, and commits it.
+type ResumableUploader struct {
+	Streams *StreamsService
+	// Dir the journals are kept in. It must already exist.
+	Dir string
+	// TTL after which an unfinished execution is aborted and the upload restarted instead of
+	// resumed. Zero means executions are always resumed.
+	TTL time.Duration
+}
+
+// NewResumableUploader returns a ResumableUploader keeping its journals in dir.
+func NewResumableUploader(client *Client, dir string, ttl time.Duration) *ResumableUploader {
+	return &ResumableUploader{Streams: client.Streams, Dir: dir, TTL: ttl}
+}
+
+// Upload the csv parts to the stream in a single execution and commit it. The same parts, in the
+// same order, must be passed when retrying an upload for it to be resumed.
+func (u *ResumableUploader) Upload(ctx context.Context, streamID int, csvParts []string) (*StreamExecution, error) {
+	checksums := make(map[int]string, len(csvParts))
+	for i, p := range csvParts {
+		checksums[i+1] = partChecksum(p)
+	}
+
+	journal, committed, err := u.resumeJournal(ctx, streamID, checksums)
+	if err != nil {
+		return nil, err
+	}
+	if committed != nil {
+		return committed, nil
+	}
+	if journal == nil {
+		execution, _, err := u.Streams.CreateExecution(ctx, streamID)
+		if err != nil {
+			return nil, err
+		}
+		journal = &UploadJournal{StreamID: streamID, ExecutionID: execution.ID, StartedAt: time.Now().UTC(), Parts: make(map[int]string)}
+		if err := u.saveJournal(journal); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, csvPart := range csvParts {
+		part := i + 1
+		if journal.Parts[part] == checksums[part] {
+			continue
+		}
+		if _, _, err := u.Streams.UploadDataPartStr(ctx, streamID, journal.ExecutionID, part, csvPart); err != nil {
+			return nil, fmt.Errorf("uploading part %d of execution %d: %v", part, journal.ExecutionID, err)
+		}
+		journal.Parts[part] = checksums[part]
+		if err := u.saveJournal(journal); err != nil {
+			return nil, err
+		}
+	}
+
+	journal.Committing = true
+	if err := u.saveJournal(journal); err != nil {
+		return nil, err
+	}
+	execution, _, err := u.Streams.CommitExecution(ctx, streamID, journal.ExecutionID)
+	if err != nil {
+		return nil, err
+	}
+	return execution, u.removeJournal(streamID)
+}
+
+// resumeJournal returns the journal of an open execution the upload can continue, or nil if a new
+// execution is needed. Stale executions are aborted. If the journaled execution already committed
+// these exact parts, that execution is returned as committed.
+func (u *ResumableUploader) resumeJournal(ctx context.Context, streamID int, checksums map[int]string) (journal *UploadJournal, committed *StreamExecution, err error) {
+	journal, err = u.Journal(streamID)
+	if err != nil || journal == nil {
+		return nil, nil, err
+	}
+	execution, err := u.findExecution(ctx, streamID, journal.ExecutionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// an execution other than the journaled one can't be resumed.
+	if execution != nil && execution.ID != journal.ExecutionID {
+		execution = nil
+	}
+	if execution != nil && journal.Committing && execution.CurrentState == ExecutionStateActive {
+		if execution, err = u.waitForCommit(ctx, journal); err != nil {
+			return nil, nil, err
+		}
+	}
+	if execution != nil && execution.CurrentState == ExecutionStateSuccess && sameChecksums(journal.Parts, checksums) {
+		// The process died between committing and removing the journal.
+		return nil, execution, u.removeJournal(streamID)
+	}
+	if execution == nil || execution.CurrentState != ExecutionStateActive {
+		return nil, nil, u.removeJournal(streamID)
+	}
+	if u.TTL > 0 && time.Since(journal.StartedAt) > u.TTL {
+		return nil, nil, u.abort(ctx, journal)
+	}
+	for part := range journal.Parts {
+		if _, ok := checksums[part]; !ok {
+			// Parts past the end of this upload were uploaded, the execution can't be reused.
+			return nil, nil, u.abort(ctx, journal)
+		}
+	}
+	return journal, nil, nil
+}
+
+// waitForCommit waits for the journaled execution, whose commit may have been sent, to finish. The
+// wait ends once the execution is past the TTL, so it's aborted instead.
+func (u *ResumableUploader) waitForCommit(ctx context.Context, journal *UploadJournal) (*StreamExecution, error) {
+	waitCtx := ctx
+	if u.TTL > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithDeadline(ctx, journal.StartedAt.Add(u.TTL))
+		defer cancel()
+	}
+	execution, err := u.Streams.WaitForExecution(waitCtx, journal.StreamID, journal.ExecutionID)
+	var executionErr ExecutionError
+	if errors.As(err, &executionErr) || (err == context.DeadlineExceeded && ctx.Err() == nil) {
+		return execution, nil
+	}
+	return execution, err
+}
+
+func (u *ResumableUploader) abort(ctx context.Context, journal *UploadJournal) error {
+	if _, err := u.Streams.AbortExecution(ctx, journal.StreamID, journal.ExecutionID); err != nil {
+		return err
+	}
+	return u.removeJournal(journal.StreamID)
+}
+
+func sameChecksums(a, b map[int]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for part, sum := range a {
+		if b[part] != sum {
+			return false
+		}
+	}
+	return true
+}
+
+// AbortStale aborts the executions of every journal in Dir older than the TTL and removes their
+// journals. It returns the journals of the aborted executions.
+func (u *ResumableUploader) AbortStale(ctx context.Context) ([]*UploadJournal, error) {
+	if u.TTL <= 0 {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(u.Dir, "stream-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var aborted []*UploadJournal
+	for _, f := range files {
+		journal, err := readJournal(f)
+		if err != nil {
+			return aborted, err
+		}
+		if time.Since(journal.StartedAt) <= u.TTL {
+			continue
+		}
+		if resp, err := u.Streams.AbortExecution(ctx, journal.StreamID, journal.ExecutionID); err != nil {
+			if resp == nil || resp.StatusCode != http.StatusNotFound {
+				return aborted, err
+			}
+		}
+		if err := u.removeJournal(journal.StreamID); err != nil {
+			return aborted, err
+		}
+		aborted = append(aborted, journal)
+	}
+	return aborted, nil
+}
+
+// Journal returns the journal of the unfinished upload for the stream, or nil if there isn't one.
+func (u *ResumableUploader) Journal(streamID int) (*UploadJournal, error) {
+	journal, err := readJournal(u.journalPath(streamID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return journal, err
+}
+
+// findExecution returns the execution, or nil if Domo doesn't know about it.
+func (u *ResumableUploader) findExecution(ctx context.Context, streamID, executionID int) (*StreamExecution, error) {
+	execution, resp, err := u.Streams.GetExecution(ctx, streamID, executionID)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return execution, err
+}
+
+func (u *ResumableUploader) journalPath(streamID int) string {
+	return filepath.Join(u.Dir, fmt.Sprintf("stream-%d.json", streamID))
+}
+
+func (u *ResumableUploader) saveJournal(journal *UploadJournal) error {
+	b, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(u.journalPath(journal.StreamID), b)
+}
+
+func (u *ResumableUploader) removeJournal(streamID int) error {
+	err := os.Remove(u.journalPath(streamID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func readJournal(path string) (*UploadJournal, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var journal *UploadJournal
+	if err := json.Unmarshal(b, &journal); err != nil {
+		return nil, fmt.Errorf("reading upload journal %s: %v", path, err)
+	}
+	if journal.Parts == nil {
+		journal.Parts = make(map[int]string)
+	}
+	return journal, nil
+}
+
+func partChecksum(csvPart string) string {
+	sum := sha256.Sum256([]byte(csvPart))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,188 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resumableTestServer fakes a stream with a single execution, 7. Uploads of the parts in failParts fail.
+type resumableTestServer struct {
+	t         *testing.T
+	state     string
+	failParts map[string]bool
+	// crashCommit fails the commit response while Domo goes on importing the execution, which
+	// still reads as ACTIVE once before it's SUCCESS.
+	crashCommit bool
+	committing  bool
+	requests    []string
+}
+
+func (s *resumableTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	switch {
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/executions"):
+		s.state = ExecutionStateActive
+		w.Write([]byte(`{"id": 7, "currentState": "ACTIVE"}`))
+	case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/executions/7"):
+		if s.state == "" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"id": 7, "currentState": %q}`, s.state)
+		if s.committing {
+			s.state, s.committing = ExecutionStateSuccess, false
+		}
+	case strings.Contains(r.URL.Path, "/part/"):
+		part := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if s.failParts[part] {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": {"status": 500, "message": "domo err msg"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": 1}`))
+	case strings.HasSuffix(r.URL.Path, "/commit"):
+		if s.crashCommit {
+			s.committing = true
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": {"status": 500, "message": "domo err msg"}}`))
+			return
+		}
+		s.state = ExecutionStateSuccess
+		w.Write([]byte(`{"id": 7, "currentState": "SUCCESS"}`))
+	case strings.HasSuffix(r.URL.Path, "/abort"):
+		s.state = ExecutionStateAborted
+	default:
+		s.t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+	}
+}
+
+func TestResumableUploader_ResumesAfterFailure(t *testing.T) {
+	fake := &resumableTestServer{t: t, failParts: map[string]bool{"2": true}}
+	client, server := testClientHandlerV2(fake.ServeHTTP)
+	defer server.Close()
+	ctx := context.Background()
+	uploader := NewResumableUploader(client, t.TempDir(), time.Hour)
+	parts := []string{"a\n", "b\n", "c\n"}
+
+	if _, err := uploader.Upload(ctx, 42, parts); err == nil {
+		t.Fatal("Expected the failed part upload to be returned")
+	}
+	journal, err := uploader.Journal(42)
+	if err != nil || journal == nil {
+		t.Fatalf("Expected a journal for the unfinished upload, got %v %v", journal, err)
+	}
+	if journal.ExecutionID != 7 || len(journal.Parts) != 1 {
+		t.Errorf("Unexpected journal %+v", journal)
+	}
+
+	fake.failParts = nil
+	fake.requests = nil
+	execution, err := uploader.Upload(ctx, 42, parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if execution.CurrentState != ExecutionStateSuccess {
+		t.Errorf("Expected a committed execution, got %+v", execution)
+	}
+	expected := []string{
+		"GET /v1/streams/42/executions/7",
+		"PUT /v1/streams/42/executions/7/part/2",
+		"PUT /v1/streams/42/executions/7/part/3",
+		"PUT /v1/streams/42/executions/7/commit",
+	}
+	if strings.Join(fake.requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected requests:\n%s", strings.Join(fake.requests, "\n"))
+	}
+	if journal, _ := uploader.Journal(42); journal != nil {
+		t.Error("Expected the journal to be removed after the commit")
+	}
+}
+
+func TestResumableUploader_ExecutionNotFound(t *testing.T) {
+	fake := &resumableTestServer{t: t, failParts: map[string]bool{"1": true}}
+	client, server := testClientHandlerV2(fake.ServeHTTP)
+	defer server.Close()
+	ctx := context.Background()
+	uploader := NewResumableUploader(client, t.TempDir(), time.Hour)
+
+	uploader.Upload(ctx, 42, []string{"a\n"})
+	// Domo no longer knows about the journaled execution, and answers with a plain 404.
+	fake.state = ""
+	fake.failParts = nil
+	fake.requests = nil
+	if _, err := uploader.Upload(ctx, 42, []string{"a\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if fake.requests[0] != "GET /v1/streams/42/executions/7" || fake.requests[1] != "POST /v1/streams/42/executions" {
+		t.Errorf("Expected a new execution to be created, got requests %q", fake.requests)
+	}
+}
+
+func TestResumableUploader_CrashAfterCommit(t *testing.T) {
+	fake := &resumableTestServer{t: t, crashCommit: true}
+	client, server := testClientHandlerV2(fake.ServeHTTP)
+	defer server.Close()
+	ctx := context.Background()
+	uploader := NewResumableUploader(client, t.TempDir(), time.Hour)
+	parts := []string{"a\n", "b\n"}
+
+	if _, err := uploader.Upload(ctx, 42, parts); err == nil {
+		t.Fatal("Expected the failed commit to be returned")
+	}
+	if journal, _ := uploader.Journal(42); journal == nil || !journal.Committing {
+		t.Fatalf("Expected the journal to be marked committing, got %+v", journal)
+	}
+
+	fake.requests = nil
+	execution, err := uploader.Upload(ctx, 42, parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if execution.ID != 7 || execution.CurrentState != ExecutionStateSuccess {
+		t.Errorf("Expected the committed execution, got %+v", execution)
+	}
+	// the execution reads as ACTIVE, then SUCCESS, and nothing is uploaded or committed again.
+	expected := []string{
+		"GET /v1/streams/42/executions/7",
+		"GET /v1/streams/42/executions/7",
+	}
+	if strings.Join(fake.requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected requests:\n%s", strings.Join(fake.requests, "\n"))
+	}
+	if journal, _ := uploader.Journal(42); journal != nil {
+		t.Error("Expected the journal to be removed")
+	}
+}
+
+func TestResumableUploader_AbortsStaleExecution(t *testing.T) {
+	fake := &resumableTestServer{t: t, failParts: map[string]bool{"1": true}}
+	client, server := testClientHandlerV2(fake.ServeHTTP)
+	defer server.Close()
+	ctx := context.Background()
+	uploader := NewResumableUploader(client, t.TempDir(), time.Hour)
+
+	uploader.Upload(ctx, 42, []string{"a\n"})
+	journal, _ := uploader.Journal(42)
+	journal.StartedAt = time.Now().Add(-2 * time.Hour)
+	if err := uploader.saveJournal(journal); err != nil {
+		t.Fatal(err)
+	}
+
+	aborted, err := uploader.AbortStale(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aborted) != 1 || aborted[0].ExecutionID != 7 {
+		t.Errorf("Expected execution 7 to be aborted, got %+v", aborted)
+	}
+	if fake.state != ExecutionStateAborted {
+		t.Errorf("Expected the execution to be aborted in Domo, got state %s", fake.state)
+	}
+	if journal, _ := uploader.Journal(42); journal != nil {
+		t.Error("Expected the journal to be removed")
+	}
+}
@@ -0,0 +1,328 @@
+package domo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrStreamSinkClosed is returned by StreamSink.Add once the sink is closed.
+var ErrStreamSinkClosed = errors.New("stream sink is closed")
+
+// StreamSinkOptions configures how a StreamSink batches and commits rows. Zero values use the defaults.
+type StreamSinkOptions struct {
+	// PartRows is the most rows uploaded in one data part. Defaults to DefaultPartRows.
+	PartRows int
+	// PartInterval after which a partial part is uploaded. Defaults to 30 seconds.
+	PartInterval time.Duration
+	// CommitInterval between execution commits, i.e. how long before added rows show up in
+	// the dataset. Defaults to 5 minutes.
+	CommitInterval time.Duration
+	// Buffer is the number of rows Add queues before it blocks. Defaults to PartRows.
+	Buffer int
+	// MaxRetries of a failed upload or commit before the rows in the execution are dropped. Defaults to 5.
+	MaxRetries int
+	// RetryInterval is the wait before the first retry, doubled on each retry. Defaults to 1 second.
+	RetryInterval time.Duration
+	// OnError is called with a StreamSinkError for every failed upload or commit.
+	OnError func(err error)
+	// OnCommit is called after an execution is committed with the number of rows it loaded.
+	OnCommit func(execution *StreamExecution, rows int)
+}
+
+// StreamSinkError describes a failure of a StreamSink to load rows.
+type StreamSinkError struct {
+	StreamID    int
+	ExecutionID int
+	// Rows dropped because the retries ran out. It's 0 for errors that will be retried.
+	Rows int
+	// Parts is the csv of the dropped rows, so they can be saved elsewhere.
+	Parts []string
+	Err   error
+}
+
+func (e StreamSinkError) Error() string {
+	if e.Rows == 0 {
+		return fmt.Sprintf("stream %d: %v", e.StreamID, e.Err)
+	}
+	return fmt.Sprintf("stream %d: dropped %d rows: %v", e.StreamID, e.Rows, e.Err)
+}
+
+// StreamSink continuously loads rows added to it into a stream. Rows are batched into data parts
+// of PartRows or every PartInterval, whichever comes first, and the execution holding the parts is
+// committed every CommitInterval. The stream should use the APPEND update method, with REPLACE only
+// the rows of the last commit are kept.
+//
+// Rows are uploaded from a single goroutine. While Domo is slow or a failed request is retried the
+// sink stops taking rows, so Add blocks once Buffer rows are queued.
+//
+// Example:
+//
+// sink := domo.NewStreamSink[Event](ctx, client, streamID, domo.StreamSinkOptions{CommitInterval: time.Minute})
+// defer sink.Close(shutdownCtx)
+// sink.Add(ctx, Event{...})
+//
+type StreamSink[T any] struct {
+	streams  *StreamsService
+	streamID int
+	opts     StreamSinkOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	rows   chan T
+	done   chan struct{}
+
+	mu        sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+	closeErr  error
+
+	// only used by the run goroutine.
+	execution *StreamExecution
+	parts     []sinkPart
+	uploaded  int
+	commitDue bool
+}
+
+type sinkPart struct {
+	csv  string
+	rows int
+}
+
+// NewStreamSink starts a StreamSink loading rows into the stream. Canceling ctx stops the sink
+// right away, leaving any uncommitted execution open; use Close to shut it down gracefully.
+func NewStreamSink[T any](ctx context.Context, client *Client, streamID int, opts StreamSinkOptions) *StreamSink[T] {
+	if opts.PartRows <= 0 {
+		opts.PartRows = DefaultPartRows
+	}
+	if opts.PartInterval <= 0 {
+		opts.PartInterval = 30 * time.Second
+	}
+	if opts.CommitInterval <= 0 {
+		opts.CommitInterval = 5 * time.Minute
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = opts.PartRows
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 5
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = time.Second
+	}
+	s := &StreamSink[T]{
+		streams:  client.Streams,
+		streamID: streamID,
+		opts:     opts,
+		rows:     make(chan T, opts.Buffer),
+		done:     make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	go s.run()
+	return s
+}
+
+// Add queues a row for upload. It blocks while the buffer is full until the sink catches up or ctx is done.
+func (s *StreamSink[T]) Add(ctx context.Context, row T) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrStreamSinkClosed
+	}
+	select {
+	case s.rows <- row:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return ErrStreamSinkClosed
+	}
+}
+
+// Consume adds every row received from rows until the channel is closed or ctx is done. It doesn't
+// close the sink.
+func (s *StreamSink[T]) Consume(ctx context.Context, rows <-chan T) error {
+	for {
+		select {
+		case row, ok := <-rows:
+			if !ok {
+				return nil
+			}
+			if err := s.Add(ctx, row); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close stops taking rows, uploads the queued rows and commits the execution. If ctx is done before
+// that finishes the sink is stopped, leaving the execution open, and ctx.Err() is returned. It returns
+// a StreamSinkError if rows were dropped while draining.
+func (s *StreamSink[T]) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		// Adds blocked on a full buffer hold the read lock, take the write lock without blocking Close.
+		go func() {
+			s.mu.Lock()
+			s.closed = true
+			close(s.rows)
+			s.mu.Unlock()
+		}()
+	})
+	select {
+	case <-s.done:
+		return s.closeErr
+	case <-ctx.Done():
+		s.cancel()
+		<-s.done
+		return ctx.Err()
+	}
+}
+
+func (s *StreamSink[T]) run() {
+	defer close(s.done)
+	defer s.cancel()
+	partTicker := time.NewTicker(s.opts.PartInterval)
+	defer partTicker.Stop()
+	commitTicker := time.NewTicker(s.opts.CommitInterval)
+	defer commitTicker.Stop()
+
+	var batch []T
+	for {
+		select {
+		case row, ok := <-s.rows:
+			if !ok {
+				s.queue(batch)
+				s.commitDue = true
+				s.closeErr = s.flush()
+				return
+			}
+			batch = append(batch, row)
+			if len(batch) < s.opts.PartRows {
+				continue
+			}
+		case <-partTicker.C:
+		case <-commitTicker.C:
+			s.commitDue = true
+		case <-s.ctx.Done():
+			return
+		}
+		s.queue(batch)
+		batch = nil
+		s.flush()
+	}
+}
+
+// queue converts a batch of rows into a data part waiting to be uploaded.
+func (s *StreamSink[T]) queue(batch []T) {
+	if len(batch) == 0 {
+		return
+	}
+	csv, err := MarshalCSV(batch, false)
+	if err != nil {
+		s.report(StreamSinkError{StreamID: s.streamID, Rows: len(batch), Err: err})
+		return
+	}
+	s.parts = append(s.parts, sinkPart{csv: csv, rows: len(batch)})
+}
+
+// flush uploads the queued parts, and commits the execution when a commit is due, retrying failures
+// with backoff. After MaxRetries the execution is aborted and its rows dropped.
+func (s *StreamSink[T]) flush() error {
+	wait := s.opts.RetryInterval
+	for retry := 0; ; retry++ {
+		err := s.sync()
+		if err == nil {
+			return nil
+		}
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+		if retry == s.opts.MaxRetries {
+			return s.drop(err)
+		}
+		s.report(StreamSinkError{StreamID: s.streamID, ExecutionID: s.executionID(), Err: err})
+		select {
+		case <-time.After(wait):
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+func (s *StreamSink[T]) sync() error {
+	if len(s.parts) == 0 {
+		s.commitDue = false
+		return nil
+	}
+	if s.execution == nil {
+		execution, _, err := s.streams.CreateExecution(s.ctx, s.streamID)
+		if err != nil {
+			return fmt.Errorf("creating execution: %v", err)
+		}
+		s.execution = execution
+	}
+	for s.uploaded < len(s.parts) {
+		part := s.uploaded + 1
+		if _, _, err := s.streams.UploadDataPartStr(s.ctx, s.streamID, s.execution.ID, part, s.parts[s.uploaded].csv); err != nil {
+			return fmt.Errorf("uploading part %d of execution %d: %v", part, s.execution.ID, err)
+		}
+		s.uploaded++
+	}
+	if !s.commitDue {
+		return nil
+	}
+	execution, _, err := s.streams.CommitExecution(s.ctx, s.streamID, s.execution.ID)
+	if err != nil {
+		return fmt.Errorf("committing execution %d: %v", s.execution.ID, err)
+	}
+	if s.opts.OnCommit != nil {
+		rows := 0
+		for _, p := range s.parts {
+			rows += p.rows
+		}
+		s.opts.OnCommit(execution, rows)
+	}
+	s.reset()
+	return nil
+}
+
+// drop aborts the current execution and discards its parts, reporting them as a StreamSinkError.
+func (s *StreamSink[T]) drop(err error) error {
+	sinkErr := StreamSinkError{StreamID: s.streamID, ExecutionID: s.executionID(), Err: err}
+	for _, p := range s.parts {
+		sinkErr.Rows += p.rows
+		sinkErr.Parts = append(sinkErr.Parts, p.csv)
+	}
+	if s.execution != nil {
+		// An execution this fails to abort is left for a Janitor to clean up.
+		s.streams.AbortExecution(s.ctx, s.streamID, s.execution.ID)
+	}
+	s.reset()
+	s.report(sinkErr)
+	return sinkErr
+}
+
+func (s *StreamSink[T]) reset() {
+	s.execution = nil
+	s.parts = nil
+	s.uploaded = 0
+	s.commitDue = false
+}
+
+func (s *StreamSink[T]) executionID() int {
+	if s.execution == nil {
+		return 0
+	}
+	return s.execution.ID
+}
+
+func (s *StreamSink[T]) report(err error) {
+	if s.opts.OnError != nil {
+		s.opts.OnError(err)
+	}
+}
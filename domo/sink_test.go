@@ -0,0 +1,197 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sinkEvent struct {
+	ID   int    `domo:"id"`
+	Name string `domo:"name"`
+}
+
+// sinkTestServer fakes the stream execution endpoints, failing the first failUploads part uploads.
+type sinkTestServer struct {
+	mu          sync.Mutex
+	failUploads int
+	executions  int
+	parts       map[int][]string
+	committed   []int
+	aborted     []int
+}
+
+// locked serves requests with h while holding the server's lock.
+func (s *sinkTestServer) locked(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		h(w, r)
+	}
+}
+
+func newSinkTestServer(t *testing.T, failUploads int) (*sinkTestServer, *Client, func()) {
+	s := &sinkTestServer{failUploads: failUploads, parts: make(map[int][]string)}
+	client, server := testClientRoutesV2(t,
+		testRoute{"POST /v1/streams/7/executions", s.locked(func(w http.ResponseWriter, r *http.Request) {
+			s.executions++
+			fmt.Fprintf(w, `{"id": %d, "currentState": "ACTIVE"}`, s.executions)
+		})},
+		testRoute{"PUT /v1/streams/7/executions/*/part/*", s.locked(func(w http.ResponseWriter, r *http.Request) {
+			if s.failUploads > 0 {
+				s.failUploads--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"status": 503, "message": "try again"}`))
+				return
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			s.parts[s.executions] = append(s.parts[s.executions], string(b))
+		})},
+		testRoute{"PUT /v1/streams/7/executions/*/commit", s.locked(func(w http.ResponseWriter, r *http.Request) {
+			s.committed = append(s.committed, s.executions)
+			fmt.Fprintf(w, `{"id": %d, "currentState": "SUCCESS"}`, s.executions)
+		})},
+		testRoute{"PUT /v1/streams/7/executions/*/abort", s.locked(func(w http.ResponseWriter, r *http.Request) {
+			s.aborted = append(s.aborted, s.executions)
+		})},
+	)
+	return s, client, server.Close
+}
+
+func TestStreamSink_BatchesAndCommitsOnClose(t *testing.T) {
+	srv, client, closeServer := newSinkTestServer(t, 0)
+	defer closeServer()
+	ctx := context.Background()
+
+	var committedRows int
+	sink := NewStreamSink[sinkEvent](ctx, client, 7, StreamSinkOptions{
+		PartRows:       2,
+		PartInterval:   time.Hour,
+		CommitInterval: time.Hour,
+		OnCommit:       func(e *StreamExecution, rows int) { committedRows += rows },
+		OnError:        func(err error) { t.Error(err) },
+	})
+	for i := 1; i <= 5; i++ {
+		if err := sink.Add(ctx, sinkEvent{ID: i, Name: "e"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Add(ctx, sinkEvent{ID: 6}); err != ErrStreamSinkClosed {
+		t.Errorf("Expected ErrStreamSinkClosed after Close, got %v", err)
+	}
+
+	expected := []string{"1,e\n2,e\n", "3,e\n4,e\n", "5,e\n"}
+	if strings.Join(srv.parts[1], "|") != strings.Join(expected, "|") {
+		t.Errorf("Unexpected parts %q", srv.parts[1])
+	}
+	if len(srv.committed) != 1 || committedRows != 5 {
+		t.Errorf("Expected a single commit of 5 rows, got %v commits and %d rows", srv.committed, committedRows)
+	}
+}
+
+func TestStreamSink_CommitsOnInterval(t *testing.T) {
+	srv, client, closeServer := newSinkTestServer(t, 0)
+	defer closeServer()
+	ctx := context.Background()
+
+	committed := make(chan int, 10)
+	sink := NewStreamSink[sinkEvent](ctx, client, 7, StreamSinkOptions{
+		PartInterval:   5 * time.Millisecond,
+		CommitInterval: 10 * time.Millisecond,
+		OnCommit:       func(e *StreamExecution, rows int) { committed <- rows },
+	})
+	rows := make(chan sinkEvent)
+	go func() {
+		rows <- sinkEvent{ID: 1}
+		rows <- sinkEvent{ID: 2}
+		close(rows)
+	}()
+	if err := sink.Consume(ctx, rows); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-committed:
+		if n != 2 {
+			t.Errorf("Expected 2 rows committed, got %d", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the execution to be committed on the interval")
+	}
+	if err := sink.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.committed) != 1 {
+		t.Errorf("Expected nothing left to commit on Close, got commits %v", srv.committed)
+	}
+}
+
+func TestStreamSink_RetriesFailedUploads(t *testing.T) {
+	srv, client, closeServer := newSinkTestServer(t, 2)
+	defer closeServer()
+	ctx := context.Background()
+
+	var errs []error
+	sink := NewStreamSink[sinkEvent](ctx, client, 7, StreamSinkOptions{
+		RetryInterval: time.Millisecond,
+		OnError:       func(err error) { errs = append(errs, err) },
+	})
+	sink.Add(ctx, sinkEvent{ID: 1, Name: "a"})
+	if err := sink.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 retried errors, got %v", errs)
+	}
+	if e, ok := errs[0].(StreamSinkError); !ok || e.Rows != 0 || e.ExecutionID != 1 {
+		t.Errorf("Unexpected error %#v", errs[0])
+	}
+	if len(srv.parts[1]) != 1 || len(srv.committed) != 1 {
+		t.Errorf("Expected the part to be uploaded and committed, got parts %q commits %v", srv.parts, srv.committed)
+	}
+}
+
+func TestStreamSink_DropsAfterMaxRetries(t *testing.T) {
+	srv, client, closeServer := newSinkTestServer(t, 100)
+	defer closeServer()
+	ctx := context.Background()
+
+	sink := NewStreamSink[sinkEvent](ctx, client, 7, StreamSinkOptions{
+		MaxRetries:    2,
+		RetryInterval: time.Millisecond,
+	})
+	sink.Add(ctx, sinkEvent{ID: 1, Name: "a"})
+	err := sink.Close(ctx)
+	e, ok := err.(StreamSinkError)
+	if !ok {
+		t.Fatalf("Expected a StreamSinkError, got %v", err)
+	}
+	if e.Rows != 1 || len(e.Parts) != 1 || e.Parts[0] != "1,a\n" {
+		t.Errorf("Expected the dropped row to be reported, got %#v", e)
+	}
+	if len(srv.aborted) != 1 || len(srv.committed) != 0 {
+		t.Errorf("Expected the execution to be aborted, got aborts %v commits %v", srv.aborted, srv.committed)
+	}
+}
+
+func TestStreamSink_CloseTimeout(t *testing.T) {
+	_, client, closeServer := newSinkTestServer(t, 100)
+	defer closeServer()
+
+	sink := NewStreamSink[sinkEvent](context.Background(), client, 7, StreamSinkOptions{RetryInterval: time.Hour})
+	sink.Add(context.Background(), sinkEvent{ID: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := sink.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected the close deadline to be exceeded, got %v", err)
+	}
+}
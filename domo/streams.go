@@ -1,10 +1,15 @@
 package domo
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // UpdateMethodAppend and UpdateMethodReplace for StreamDataset UpdateMethod.
@@ -13,24 +18,62 @@ const (
 	UpdateMethodReplace = "REPLACE"
 )
 
-// StreamDataset describes the Domo Dataset for a Domo Stream.
+// Stream execution states reported in StreamExecution.CurrentState.
+const (
+	ExecutionStateActive  = "ACTIVE"
+	ExecutionStateSuccess = "SUCCESS"
+	ExecutionStateError   = "ERROR"
+	ExecutionStateAborted = "ABORTED"
+)
+
+// ExecutionPollInterval is the first delay between execution status checks in WaitForExecution.
+// The delay doubles after each check up to ExecutionPollMaxInterval.
+var ExecutionPollInterval = 2 * time.Second
+
+// ExecutionPollMaxInterval is the longest delay between execution status checks in WaitForExecution.
+var ExecutionPollMaxInterval = 30 * time.Second
+
+// ErrNoStream is returned by FindStream when the dataset has no stream.
+var ErrNoStream = errors.New("dataset has no stream")
+
+// ExecutionError is returned by WaitForExecution when an execution ends in a state other than SUCCESS.
+type ExecutionError struct {
+	StreamID    int
+	ExecutionID int
+	State       string
+}
+
+func (e ExecutionError) Error() string {
+	return fmt.Sprintf("stream %d execution %d ended in state %s", e.StreamID, e.ExecutionID, e.State)
+}
+
+// IsTerminalExecutionState reports whether an execution in the state is finished, successfully or not.
+func IsTerminalExecutionState(state string) bool {
+	switch state {
+	case ExecutionStateSuccess, ExecutionStateError, ExecutionStateAborted:
+		return true
+	}
+	return false
+}
+
+// StreamDataset describes the Domo Dataset for a Domo Stream. The API sends the dataset as "dataSet".
 type StreamDataset struct {
 	ID            int              `json:"id,omitempty"`
-	Dataset       *Dataset         `json:"dataset,omitempty"`
+	Dataset       *Dataset         `json:"dataSet,omitempty"`
 	UpdateMethod  string           `json:"updateMethod,omitempty"`
-	CreatedAt     string           `json:"createdAt,omitempty"`
-	ModifiedAt    string           `json:"modifiedAt,omitempty"`
+	CreatedAt     *Timestamp       `json:"createdAt,omitempty"`
+	ModifiedAt    *Timestamp       `json:"modifiedAt,omitempty"`
 	LastExecution *StreamExecution `json:"lastExecution,omitempty"`
 }
 
 // StreamExecution describes the Execution for a given Domo Stream.
 type StreamExecution struct {
-	ID           int    `json:"id,omitempty"`
-	StartedAt    string `json:"startedAt,omitempty"`
-	EndedAt      string `json:"endedAt,omitempty"`
-	CurrentState string `json:"currentState,omitempty"`
-	CreatedAt    string `json:"createdAt,omitempty"`
-	ModifiedAt   string `json:"modifiedAt,omitempty"`
+	ID           int        `json:"id,omitempty"`
+	StartedAt    *Timestamp `json:"startedAt,omitempty"`
+	EndedAt      *Timestamp `json:"endedAt,omitempty"`
+	CurrentState string     `json:"currentState,omitempty"`
+	CreatedAt    *Timestamp `json:"createdAt,omitempty"`
+	ModifiedAt   *Timestamp `json:"modifiedAt,omitempty"`
 }
 
 // StreamDatasetSchema describes the schema for a StreamDataset.
@@ -41,11 +84,22 @@ type StreamDatasetSchema struct {
 
 // StreamFragment contains some details about a data part upload
 type StreamFragment struct {
-	ID           int    `json:"id,omitempty"`
-	StartedAt    string `json:"startedAt,omitempty"`
-	CurrentState string `json:"currentState,omitempty"`
-	CreatedAt    string `json:"createdAt,omitempty"`
-	ModifiedAt   string `json:"modifiedAt,omitempty"`
+	ID           int        `json:"id,omitempty"`
+	StartedAt    *Timestamp `json:"startedAt,omitempty"`
+	CurrentState string     `json:"currentState,omitempty"`
+	CreatedAt    *Timestamp `json:"createdAt,omitempty"`
+	ModifiedAt   *Timestamp `json:"modifiedAt,omitempty"`
+}
+
+// StreamSearchOptions selects the streams returned by StreamsService.Search. Set either DatasetID or OwnerID.
+type StreamSearchOptions struct {
+	// DatasetID matches the stream of a dataset (dataSource.id).
+	DatasetID string
+	// OwnerID matches the streams whose dataset is owned by the user (dataSource.owner.id).
+	OwnerID int
+	// Fields to return for each stream. Domo only returns the stream and dataset ids by default, use "all" for
+	// every field.
+	Fields string
 }
 
 // StreamsService handles communication with the streams
@@ -78,6 +132,70 @@ func (s *StreamsService) List(ctx context.Context, limit, offset int) ([]*Stream
 	return streams, resp, nil
 }
 
+// Search the streams by dataset id or by dataset owner.
+//
+// Domo API Docs: https://developer.domo.com/docs/streams-api-reference/streams#Search%20Streams
+func (s *StreamsService) Search(ctx context.Context, opts StreamSearchOptions) ([]*StreamDataset, *http.Response, error) {
+	q := url.Values{}
+	switch {
+	case opts.DatasetID != "" && opts.OwnerID != 0:
+		return nil, nil, fmt.Errorf("search by either dataset id or owner id, not both")
+	case opts.DatasetID != "":
+		q.Set("q", "dataSource.id:"+opts.DatasetID)
+	case opts.OwnerID != 0:
+		q.Set("q", "dataSource.owner.id:"+strconv.Itoa(opts.OwnerID))
+	default:
+		return nil, nil, fmt.Errorf("search requires a dataset id or owner id")
+	}
+	if opts.Fields != "" {
+		q.Set("fields", opts.Fields)
+	}
+	u := fmt.Sprintf("v1/streams/search?%s", q.Encode())
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var streams []*StreamDataset
+	resp, err := s.client.Do(ctx, req, &streams)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return streams, resp, nil
+}
+
+// FindStream returns the stream for the dataset id, or ErrNoStream if the dataset has no stream. Domo can't
+// add a stream to an existing dataset, use CreateStream to create a stream with a new dataset instead.
+func (s *StreamsService) FindStream(ctx context.Context, datasetID string) (*StreamDataset, *http.Response, error) {
+	streams, resp, err := s.Search(ctx, StreamSearchOptions{DatasetID: datasetID, Fields: "all"})
+	if err != nil {
+		return nil, resp, err
+	}
+	if len(streams) == 0 {
+		return nil, resp, ErrNoStream
+	}
+	return streams[0], resp, nil
+}
+
+// FindOrCreateStream returns the stream for the dataset id and whether it was created. If the dataset has no
+// stream, or datasetID is empty, a new stream is created from schema. Domo can't add a stream to an existing
+// dataset, so a created stream comes with a new dataset whose id is in the returned StreamDataset.
+func (s *StreamsService) FindOrCreateStream(ctx context.Context, datasetID string, schema StreamDatasetSchema) (*StreamDataset, bool, *http.Response, error) {
+	if datasetID != "" {
+		stream, resp, err := s.FindStream(ctx, datasetID)
+		if err != ErrNoStream {
+			return stream, false, resp, err
+		}
+	}
+	stream, resp, err := s.CreateStream(ctx, schema)
+	if err != nil {
+		return nil, false, resp, err
+	}
+	return stream, true, resp, nil
+}
+
 // Info for the stream for the given stream id.
 func (s *StreamsService) Info(ctx context.Context, streamID int) (*StreamDataset, *http.Response, error) {
 	u := fmt.Sprintf("v1/streams/%d", streamID)
@@ -202,6 +320,58 @@ func (s *StreamsService) ListExecutions(ctx context.Context, streamID, limit, of
 	return streamExecutions, resp, nil
 }
 
+// GetExecution retrieves a single stream execution.
+func (s *StreamsService) GetExecution(ctx context.Context, streamID, executionID int) (*StreamExecution, *http.Response, error) {
+	u := fmt.Sprintf("v1/streams/%d/executions/%d", streamID, executionID)
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var sExecution *StreamExecution
+	resp, err := s.client.Do(ctx, req, &sExecution)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return sExecution, resp, nil
+}
+
+// WaitForExecution polls a committed execution until Domo has finished importing its data, backing off from
+// ExecutionPollInterval to ExecutionPollMaxInterval between checks. Use a ctx with a deadline to limit how long it
+// waits. If the execution ends in ERROR or ABORTED the execution is returned along with an ExecutionError.
+func (s *StreamsService) WaitForExecution(ctx context.Context, streamID, executionID int) (*StreamExecution, error) {
+	interval := ExecutionPollInterval
+	for {
+		execution, _, err := s.GetExecution(ctx, streamID, executionID)
+		if err != nil {
+			return nil, err
+		}
+		if execution == nil {
+			return nil, fmt.Errorf("stream %d execution %d not found", streamID, executionID)
+		}
+		if IsTerminalExecutionState(execution.CurrentState) {
+			if execution.CurrentState != ExecutionStateSuccess {
+				return execution, ExecutionError{StreamID: streamID, ExecutionID: executionID, State: execution.CurrentState}
+			}
+			return execution, nil
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return execution, ctx.Err()
+		case <-t.C:
+		}
+		interval *= 2
+		if interval > ExecutionPollMaxInterval {
+			interval = ExecutionPollMaxInterval
+		}
+	}
+}
+
 // CommitExecution finalizes a stream execution and inserts data parts into the dataset for the stream.
 func (s *StreamsService) CommitExecution(ctx context.Context, streamID, executionID int) (*StreamExecution, *http.Response, error) {
 	u := fmt.Sprintf("v1/streams/%d/executions/%d/commit", streamID, executionID)
@@ -240,16 +410,20 @@ func (s *StreamsService) AbortExecution(ctx context.Context, streamID, execution
 // UploadDataPartStr uploads a csv given as a string to an active stream execution.
 func (s *StreamsService) UploadDataPartStr(ctx context.Context, streamID, executionID, part int, csvData string) (*StreamFragment, *http.Response, error) {
 	u := fmt.Sprintf("v1/streams/%d/executions/%d/part/%d", streamID, executionID, part)
-	buf := new(bytes.Buffer)
-	buf.WriteString(csvData)
-	req, err := s.client.NewRequest("PUT", u, buf)
+	var sFragment *StreamFragment
+	resp, err := s.client.doUpload(ctx, "PUT", u, strings.NewReader(csvData), int64(len(csvData)), part, &sFragment)
 	if err != nil {
-		return nil, nil, err
+		return nil, resp, err
 	}
-	req.Header.Set("Content-Type", "text/csv")
+	return sFragment, resp, nil
+}
 
+// UploadDataPartReader streams csv from r to an active stream execution, like UploadDataPartStr without holding
+// the part in memory.
+func (s *StreamsService) UploadDataPartReader(ctx context.Context, streamID, executionID, part int, r io.Reader) (*StreamFragment, *http.Response, error) {
+	u := fmt.Sprintf("v1/streams/%d/executions/%d/part/%d", streamID, executionID, part)
 	var sFragment *StreamFragment
-	resp, err := s.client.Do(ctx, req, &sFragment)
+	resp, err := s.client.doUpload(ctx, "PUT", u, r, -1, part, &sFragment)
 	if err != nil {
 		return nil, resp, err
 	}
@@ -258,5 +432,61 @@ func (s *StreamsService) UploadDataPartStr(ctx context.Context, streamID, execut
 
 // UploadDataPart uploads an array of structs serialized to csv to an active stream execution.
 func (s *StreamsService) UploadDataPart(ctx context.Context, streamID, executionID, part int, data []interface{}) (*StreamFragment, *http.Response, error) {
-	return nil, nil, fmt.Errorf("UploadDataPart not implemented")
+	csv, err := MarshalCSV(data, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.UploadDataPartStr(ctx, streamID, executionID, part, csv)
+}
+
+// ExecuteUpload creates an execution for the stream, uploads each csv part in order and commits the execution.
+// If a part fails to upload the execution is aborted.
+func (s *StreamsService) ExecuteUpload(ctx context.Context, streamID int, csvParts ...string) (*StreamExecution, *http.Response, error) {
+	execution, resp, err := s.CreateExecution(ctx, streamID)
+	if err != nil {
+		return nil, resp, err
+	}
+	for i, csvPart := range csvParts {
+		_, resp, err = s.UploadDataPartStr(ctx, streamID, execution.ID, i+1, csvPart)
+		if err != nil {
+			return nil, resp, s.abortFailedExecution(ctx, streamID, execution.ID, err)
+		}
+	}
+	return s.CommitExecution(ctx, streamID, execution.ID)
+}
+
+// abortFailedExecution aborts an execution that failed with err. It returns err, wrapped with the abort's
+// error if the execution couldn't be aborted either.
+func (s *StreamsService) abortFailedExecution(ctx context.Context, streamID, executionID int, err error) error {
+	if _, abortErr := s.AbortExecution(ctx, streamID, executionID); abortErr != nil {
+		return fmt.Errorf("%w (aborting execution %d: %v)", err, executionID, abortErr)
+	}
+	return err
+}
+
+// ExecuteUploadReader creates an execution for the stream, uploads the csv read from r in parts of partRows
+// rows and commits the execution. Only one part is held in memory at a time. It returns the committed execution
+// and the number of rows uploaded. If reading r or uploading a part fails the execution is aborted.
+func (s *StreamsService) ExecuteUploadReader(ctx context.Context, streamID int, r io.Reader, partRows int) (*StreamExecution, int, error) {
+	if partRows < 1 {
+		return nil, 0, fmt.Errorf("partRows must be above 0, but %d is not", partRows)
+	}
+	execution, _, err := s.CreateExecution(ctx, streamID)
+	if err != nil {
+		return nil, 0, err
+	}
+	part, total := 0, 0
+	err = splitCSV(r, partRows, func(csvPart string, rows int) error {
+		part++
+		if _, _, err := s.UploadDataPartStr(ctx, streamID, execution.ID, part, csvPart); err != nil {
+			return fmt.Errorf("uploading part %d of execution %d: %v", part, execution.ID, err)
+		}
+		total += rows
+		return nil
+	})
+	if err != nil {
+		return nil, total, s.abortFailedExecution(ctx, streamID, execution.ID, err)
+	}
+	committed, _, err := s.CommitExecution(ctx, streamID, execution.ID)
+	return committed, total, err
 }
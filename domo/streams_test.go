@@ -2,8 +2,13 @@ package domo
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_GetStreamDetails(t *testing.T) {
@@ -21,7 +26,16 @@ func Test_GetStreamDetails(t *testing.T) {
 	if streamInfo.ID != 42 {
 		t.Error("Got wrong stream")
 	}
-
+	if streamInfo.Dataset == nil || streamInfo.Dataset.ID != "0c1e0dbe-9f71-4625-9b50-b79e6e4266f2" || streamInfo.Dataset.Name != "Leonhard Euler Party" {
+		t.Errorf("Expected the stream's dataSet to be decoded, got %+v", streamInfo.Dataset)
+	}
+	b, err := json.Marshal(StreamDataset{ID: 42, Dataset: &Dataset{ID: "abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"dataSet":{"id":"abc"`) {
+		t.Errorf("Expected the dataset to be encoded as dataSet, got %s", b)
+	}
 }
 
 func Test_GetStreamDetailsBadID(t *testing.T) {
@@ -579,3 +593,207 @@ func Test_UploadDataPartBadCsvColumnNumbers(t *testing.T) {
 	// more columns than schema
 	// less columns than schema
 }
+
+func Test_GetExecution(t *testing.T) {
+	client, server := testClientFileV2(http.StatusOK, "../test_data/streams/get_stream_execution.json")
+	ctx := context.Background()
+	defer server.Close()
+
+	res, _, err := client.Streams.GetExecution(ctx, 42, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ID != 1 {
+		t.Error("Got wrong stream execution id")
+	}
+	if res.CurrentState != ExecutionStateActive {
+		t.Errorf("Expected execution state %s, got %s", ExecutionStateActive, res.CurrentState)
+	}
+}
+
+func Test_WaitForExecution(t *testing.T) {
+	defer func(interval, max time.Duration) {
+		ExecutionPollInterval, ExecutionPollMaxInterval = interval, max
+	}(ExecutionPollInterval, ExecutionPollMaxInterval)
+	ExecutionPollInterval, ExecutionPollMaxInterval = time.Millisecond, 2*time.Millisecond
+
+	tests := []struct {
+		name      string
+		states    []string
+		wantState string
+		wantErr   bool
+	}{
+		{name: "Succeeds", states: []string{"ACTIVE", "ACTIVE", "SUCCESS"}, wantState: "SUCCESS"},
+		{name: "Errors", states: []string{"ACTIVE", "ERROR"}, wantState: "ERROR", wantErr: true},
+		{name: "Aborted", states: []string{"ABORTED"}, wantState: "ABORTED", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polls := 0
+			client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"id": 1, "currentState": %q}`, tt.states[polls])
+				polls++
+			})
+			defer server.Close()
+
+			execution, err := client.Streams.WaitForExecution(context.Background(), 42, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if tt.wantErr {
+				if e, ok := err.(ExecutionError); !ok || e.State != tt.wantState {
+					t.Errorf("Expected an ExecutionError with state %s, got %v", tt.wantState, err)
+				}
+			}
+			if execution.CurrentState != tt.wantState {
+				t.Errorf("Expected state %s, got %s", tt.wantState, execution.CurrentState)
+			}
+			if polls != len(tt.states) {
+				t.Errorf("Expected %d polls, got %d", len(tt.states), polls)
+			}
+		})
+	}
+}
+
+func Test_WaitForExecutionTimeout(t *testing.T) {
+	client, server := testClientStringV2(http.StatusOK, `{"id": 1, "currentState": "ACTIVE"}`)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Streams.WaitForExecution(ctx, 42, 1)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the context deadline error, got %v", err)
+	}
+}
+
+func Test_SearchStreams(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    StreamSearchOptions
+		wantQ   string
+		wantErr bool
+	}{
+		{name: "By dataset", opts: StreamSearchOptions{DatasetID: "0c1e0dbe-9f71-4625-9b50-b79e6e4266f2"}, wantQ: "dataSource.id:0c1e0dbe-9f71-4625-9b50-b79e6e4266f2"},
+		{name: "By owner", opts: StreamSearchOptions{OwnerID: 27, Fields: "all"}, wantQ: "dataSource.owner.id:27"},
+		{name: "No criteria", opts: StreamSearchOptions{}, wantErr: true},
+		{name: "Both criteria", opts: StreamSearchOptions{DatasetID: "a", OwnerID: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/streams/search" {
+					t.Errorf("Unexpected path %s", r.URL.Path)
+				}
+				if q := r.URL.Query().Get("q"); q != tt.wantQ {
+					t.Errorf("Expected q=%s, got %s", tt.wantQ, q)
+				}
+				if f := r.URL.Query().Get("fields"); f != tt.opts.Fields {
+					t.Errorf("Expected fields=%s, got %s", tt.opts.Fields, f)
+				}
+				http.ServeFile(w, r, "../test_data/streams/list_streams.json")
+			})
+			defer server.Close()
+
+			streams, _, err := client.Streams.Search(context.Background(), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if !tt.wantErr && (len(streams) != 1 || streams[0].Dataset == nil || streams[0].Dataset.ID != "0c1e0dbe-9f71-4625-9b50-b79e6e4266f2") {
+				t.Errorf("Unexpected streams %+v", streams)
+			}
+		})
+	}
+}
+
+func Test_ExecuteUploadAbortFails(t *testing.T) {
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/executions"):
+			w.Write([]byte(`{"id": 7, "currentState": "ACTIVE"}`))
+		case strings.Contains(r.URL.Path, "/part/"):
+			http.Error(w, `{"error": {"status": 400, "message": "bad part"}}`, http.StatusBadRequest)
+		case strings.HasSuffix(r.URL.Path, "/abort"):
+			http.Error(w, `{"error": {"status": 500, "message": "abort failed"}}`, http.StatusInternalServerError)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer server.Close()
+
+	_, _, err := client.Streams.ExecuteUpload(context.Background(), 1, "a,b\n")
+	var se Error
+	if !errors.As(err, &se) || se.Status != 400 {
+		t.Fatalf("Expected the part's domo error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "abort failed") {
+		t.Errorf("Expected the abort error to be included, got %v", err)
+	}
+}
+
+func Test_FindStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		searchBody string
+		wantErr    error
+		wantID     int
+	}{
+		{name: "Found", searchBody: `[{"id": 42, "dataSet": {"id": "abc"}}]`, wantID: 42},
+		{name: "No stream", searchBody: `[]`, wantErr: ErrNoStream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "GET" || r.URL.Path != "/v1/streams/search" {
+					t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+					return
+				}
+				w.Write([]byte(tt.searchBody))
+			})
+			defer server.Close()
+
+			stream, _, err := client.Streams.FindStream(context.Background(), "abc")
+			if err != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && stream.ID != tt.wantID {
+				t.Errorf("Expected stream %d, got %d", tt.wantID, stream.ID)
+			}
+		})
+	}
+}
+
+func Test_FindOrCreateStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		datasetID   string
+		searchBody  string
+		wantCreated bool
+		wantID      int
+	}{
+		{name: "Found", datasetID: "abc", searchBody: `[{"id": 42, "dataSet": {"id": "abc"}}]`, wantID: 42},
+		{name: "Created", datasetID: "abc", searchBody: `[]`, wantCreated: true, wantID: 43},
+		{name: "No dataset", wantCreated: true, wantID: 43},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := testClientRoutesV2(t,
+				testRoute{"GET /v1/streams/search", testBody(tt.searchBody)},
+				testRoute{"POST /v1/streams", testBody(`{"id": 43, "dataSet": {"id": "def"}}`)},
+			)
+			defer server.Close()
+
+			schema := StreamDatasetSchema{DatasetSchema: &DatasetSchema{Name: "test"}, UpdateMethod: UpdateMethodAppend}
+			stream, created, _, err := client.Streams.FindOrCreateStream(context.Background(), tt.datasetID, schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created != tt.wantCreated {
+				t.Errorf("Expected created=%t", tt.wantCreated)
+			}
+			if stream.ID != tt.wantID {
+				t.Errorf("Expected stream %d, got %d", tt.wantID, stream.ID)
+			}
+		})
+	}
+}
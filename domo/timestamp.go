@@ -0,0 +1,81 @@
+package domo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// timestampLayouts are the layouts of Domo API timestamps that aren't also DATE or DATETIME cell values.
+var timestampLayouts = []string{"2006-01-02 03:04:05 PM", "2006-01-02 3:04:05 PM"}
+
+// Timestamp is a time in a Domo API model. It unmarshals every format Domo emits: ISO 8601 strings with
+// or without a zone, dates, audit log times like "2017-12-13 10:57:35 PM" and epoch milliseconds,
+// either as numbers or strings. It marshals as RFC 3339.
+type Timestamp struct {
+	time.Time
+}
+
+// ParseTimestamp parses a timestamp in any of the formats Domo emits.
+func ParseTimestamp(s string) (Timestamp, error) {
+	if t, err := parseCSVTime(s); err == nil {
+		return Timestamp{t}, nil
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return Timestamp{t}, nil
+		}
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return Timestamp{time.Unix(0, ms*int64(time.Millisecond)).UTC()}, nil
+	}
+	return Timestamp{}, fmt.Errorf("unrecognized timestamp %q", s)
+}
+
+// UnmarshalJSON decodes a timestamp string or epoch milliseconds. null and "" leave the zero time.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			*t = Timestamp{}
+			return nil
+		}
+	}
+	parsed, err := ParseTimestamp(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// MarshalJSON encodes the timestamp as an RFC 3339 string, or null if it's the zero time.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(strconv.Quote(t.Format(time.RFC3339Nano))), nil
+}
+
+// String formats the timestamp like time.Time.
+func (t Timestamp) String() string {
+	return t.Time.String()
+}
+
+// firstTimestamp returns the first of the timestamps that's set, if any.
+func firstTimestamp(timestamps ...*Timestamp) (time.Time, bool) {
+	for _, t := range timestamps {
+		if t != nil && !t.IsZero() {
+			return t.Time, true
+		}
+	}
+	return time.Time{}, false
+}
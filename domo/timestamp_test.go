@@ -0,0 +1,81 @@
+package domo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func mustTimestamp(s string) *Timestamp {
+	t, err := ParseTimestamp(s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func TestTimestamp_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json     string
+		expected time.Time
+	}{
+		{`"2016-05-26T22:20:21Z"`, time.Date(2016, 5, 26, 22, 20, 21, 0, time.UTC)},
+		{`"2016-05-26T22:20:21.250Z"`, time.Date(2016, 5, 26, 22, 20, 21, 250e6, time.UTC)},
+		{`"2016-05-26T22:20:21-07:00"`, time.Date(2016, 5, 27, 5, 20, 21, 0, time.UTC)},
+		{`"2016-05-26T22:20:21"`, time.Date(2016, 5, 26, 22, 20, 21, 0, time.UTC)},
+		{`"2016-05-26 22:20:21"`, time.Date(2016, 5, 26, 22, 20, 21, 0, time.UTC)},
+		{`"2016-05-26"`, time.Date(2016, 5, 26, 0, 0, 0, 0, time.UTC)},
+		{`"2017-12-13 10:57:35 PM"`, time.Date(2017, 12, 13, 22, 57, 35, 0, time.UTC)},
+		{`1513205855000`, time.Date(2017, 12, 13, 22, 57, 35, 0, time.UTC)},
+		{`"1513205855000"`, time.Date(2017, 12, 13, 22, 57, 35, 0, time.UTC)},
+		{`""`, time.Time{}},
+		{`null`, time.Time{}},
+	}
+	for _, test := range tests {
+		var ts Timestamp
+		if err := json.Unmarshal([]byte(test.json), &ts); err != nil {
+			t.Errorf("Unmarshalling %s: %v", test.json, err)
+			continue
+		}
+		if !ts.Equal(test.expected) {
+			t.Errorf("Expected %s to be %s, got %s", test.json, test.expected, ts)
+		}
+	}
+
+	var ts Timestamp
+	if err := json.Unmarshal([]byte(`"yesterday"`), &ts); err == nil {
+		t.Error("Expected an error for an unrecognized timestamp")
+	}
+}
+
+func TestTimestamp_MarshalJSON(t *testing.T) {
+	user := User{ID: 7, UpdatedAt: mustTimestamp("2016-05-26T22:20:21Z")}
+	b, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"id":7,"updatedAt":"2016-05-26T22:20:21Z"}`
+	if string(b) != expected {
+		t.Errorf("Expected %s, got %s", expected, b)
+	}
+
+	var decoded User
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.CreatedAt != nil || !decoded.UpdatedAt.Equal(user.UpdatedAt.Time) {
+		t.Errorf("Unexpected round trip %+v", decoded)
+	}
+	if b, _ := json.Marshal(Timestamp{}); string(b) != "null" {
+		t.Errorf("Expected the zero time to marshal as null, got %s", b)
+	}
+}
+
+func TestDomoFormats(t *testing.T) {
+	if _, err := time.Parse(DomoDateFormat, "2018-09-17"); err != nil {
+		t.Error(err)
+	}
+	if _, err := time.Parse(DomoTimestampFormat, "2018-09-17T15:04:05Z"); err != nil {
+		t.Error(err)
+	}
+}
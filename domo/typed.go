@@ -0,0 +1,277 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// DefaultPartRows is the number of rows TypedStream uploads per data part.
+const DefaultPartRows = 50000
+
+// TypedDataset is a handle to a Domo Dataset whose rows are the struct T. The schema is
+// generated from T's domo struct tags the same way GenerateDataSetSchema does.
+//
+// Example:
+//
+// users := domo.NewTypedDataset[UserRow](client, "")
+// users.Create(ctx, "Users", "users from our app")
+// users.Append(ctx, rows)
+type TypedDataset[T any] struct {
+	client *Client
+	// ID of the Domo Dataset. Set by Create for new datasets.
+	ID string
+}
+
+// NewTypedDataset returns a TypedDataset for the dataset id. Leave the id empty when the
+// dataset will be created with Create.
+func NewTypedDataset[T any](client *Client, id string) *TypedDataset[T] {
+	return &TypedDataset[T]{client: client, ID: id}
+}
+
+// Schema generated from T.
+func (d *TypedDataset[T]) Schema() (Schema, error) {
+	return GenerateDataSetSchema(typeOf[T]())
+}
+
+// Create a new Domo Dataset with T's schema and point the handle at it.
+func (d *TypedDataset[T]) Create(ctx context.Context, name, description string) (*Dataset, *http.Response, error) {
+	schema, err := d.Schema()
+	if err != nil {
+		return nil, nil, err
+	}
+	ds, resp, err := d.client.Datasets.Create(ctx, Dataset{Name: name, Description: description, Schema: schema})
+	if err != nil {
+		return nil, resp, err
+	}
+	d.ID = ds.ID
+	return ds, resp, nil
+}
+
+// Append rows to the dataset.
+func (d *TypedDataset[T]) Append(ctx context.Context, rows []T) (*http.Response, error) {
+	return d.importRows(ctx, UpdateMethodAppend, rows)
+}
+
+// Replace all the data in the dataset with rows.
+func (d *TypedDataset[T]) Replace(ctx context.Context, rows []T) (*http.Response, error) {
+	return d.importRows(ctx, UpdateMethodReplace, rows)
+}
+
+func (d *TypedDataset[T]) importRows(ctx context.Context, updateMethod string, rows []T) (*http.Response, error) {
+	csv, err := MarshalCSV(rows, false)
+	if err != nil {
+		return nil, err
+	}
+	return d.client.Datasets.ImportDataStr(ctx, d.ID, updateMethod, csv)
+}
+
+// ReadAll downloads every row in the dataset.
+func (d *TypedDataset[T]) ReadAll(ctx context.Context) ([]T, error) {
+	return readAll[T](ctx, d.client, d.ID)
+}
+
+// Query the dataset with sql and decode the result rows into T. Result columns are matched
+// to T's fields by name, so alias computed columns to match the struct.
+func (d *TypedDataset[T]) Query(ctx context.Context, sqlQuery string) ([]T, error) {
+	return query[T](ctx, d.client, d.ID, sqlQuery)
+}
+
+// CheckSchema compares T's schema with the dataset's schema. It returns a SchemaDiffError
+// describing the differences, or nil if they match. See FindSchemaChanges.
+func (d *TypedDataset[T]) CheckSchema(ctx context.Context) error {
+	return d.client.Datasets.FindSchemaChanges(ctx, d.ID, typeOf[T]())
+}
+
+// TypedStream is a handle to a Domo Stream whose rows are the struct T. Data is uploaded
+// through stream executions split into parts of PartRows rows.
+//
+// A stream has a single update method, so Append and Replace switch the stream's update
+// method when it differs. The change is kept on the stream and applies to every other
+// upload to it until it's switched back.
+type TypedStream[T any] struct {
+	client *Client
+	// ID of the Domo Stream. Set by Create for new streams.
+	ID int
+	// DatasetID of the stream's dataset. It's looked up from the stream if left empty.
+	DatasetID string
+	// PartRows is the number of rows per uploaded data part. Defaults to DefaultPartRows.
+	PartRows int
+
+	updateMethod string
+}
+
+// NewTypedStream returns a TypedStream for the stream id. Use a 0 id when the stream will
+// be created with Create.
+func NewTypedStream[T any](client *Client, id int) *TypedStream[T] {
+	return &TypedStream[T]{client: client, ID: id}
+}
+
+// Schema generated from T.
+func (s *TypedStream[T]) Schema() (Schema, error) {
+	return GenerateDataSetSchema(typeOf[T]())
+}
+
+// Create a new Domo Stream and Dataset with T's schema and point the handle at it.
+func (s *TypedStream[T]) Create(ctx context.Context, name, description, updateMethod string) (*StreamDataset, *http.Response, error) {
+	schema, err := s.Schema()
+	if err != nil {
+		return nil, nil, err
+	}
+	sds := StreamDatasetSchema{
+		DatasetSchema: &DatasetSchema{Name: name, Description: description, Schema: schema},
+		UpdateMethod:  updateMethod,
+	}
+	stream, resp, err := s.client.Streams.CreateStream(ctx, sds)
+	if err != nil {
+		return nil, resp, err
+	}
+	s.ID = stream.ID
+	s.updateMethod = stream.UpdateMethod
+	if stream.Dataset != nil {
+		s.DatasetID = stream.Dataset.ID
+	}
+	return stream, resp, nil
+}
+
+// Append rows to the stream's dataset in a single execution. The stream's update method is
+// changed to UpdateMethodAppend if it isn't already.
+func (s *TypedStream[T]) Append(ctx context.Context, rows []T) (*StreamExecution, error) {
+	return s.execute(ctx, UpdateMethodAppend, rows)
+}
+
+// Replace all the data in the stream's dataset with rows in a single execution. The stream's
+// update method is changed to UpdateMethodReplace if it isn't already.
+func (s *TypedStream[T]) Replace(ctx context.Context, rows []T) (*StreamExecution, error) {
+	return s.execute(ctx, UpdateMethodReplace, rows)
+}
+
+func (s *TypedStream[T]) execute(ctx context.Context, updateMethod string, rows []T) (*StreamExecution, error) {
+	parts, err := s.csvParts(rows)
+	if err != nil {
+		return nil, err
+	}
+	if s.updateMethod == "" {
+		if err := s.loadInfo(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if s.updateMethod != updateMethod {
+		if _, _, err := s.client.Streams.ModifyStreamUpdateMethod(ctx, s.ID, updateMethod == UpdateMethodAppend); err != nil {
+			return nil, err
+		}
+		s.updateMethod = updateMethod
+	}
+	execution, _, err := s.client.Streams.ExecuteUpload(ctx, s.ID, parts...)
+	return execution, err
+}
+
+// csvParts serializes rows into csv data parts of PartRows rows.
+func (s *TypedStream[T]) csvParts(rows []T) ([]string, error) {
+	partRows := s.PartRows
+	if partRows < 1 {
+		partRows = DefaultPartRows
+	}
+	var parts []string
+	for start := 0; start < len(rows); start += partRows {
+		end := start + partRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+		csv, err := MarshalCSV(rows[start:end], false)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, csv)
+	}
+	return parts, nil
+}
+
+// ReadAll downloads every row in the stream's dataset.
+func (s *TypedStream[T]) ReadAll(ctx context.Context) ([]T, error) {
+	id, err := s.datasetID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return readAll[T](ctx, s.client, id)
+}
+
+// Query the stream's dataset with sql and decode the result rows into T.
+func (s *TypedStream[T]) Query(ctx context.Context, sqlQuery string) ([]T, error) {
+	id, err := s.datasetID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return query[T](ctx, s.client, id, sqlQuery)
+}
+
+// CheckSchema compares T's schema with the stream dataset's schema. See FindSchemaChanges.
+func (s *TypedStream[T]) CheckSchema(ctx context.Context) error {
+	id, err := s.datasetID(ctx)
+	if err != nil {
+		return err
+	}
+	return s.client.Datasets.FindSchemaChanges(ctx, id, typeOf[T]())
+}
+
+func (s *TypedStream[T]) datasetID(ctx context.Context) (string, error) {
+	if s.DatasetID != "" {
+		return s.DatasetID, nil
+	}
+	if err := s.loadInfo(ctx); err != nil {
+		return "", err
+	}
+	if s.DatasetID == "" {
+		return "", fmt.Errorf("stream %d has no dataset", s.ID)
+	}
+	return s.DatasetID, nil
+}
+
+// loadInfo looks up the stream's dataset and update method.
+func (s *TypedStream[T]) loadInfo(ctx context.Context) error {
+	stream, _, err := s.client.Streams.Info(ctx, s.ID)
+	if err != nil {
+		return err
+	}
+	if stream.Dataset != nil && s.DatasetID == "" {
+		s.DatasetID = stream.Dataset.ID
+	}
+	s.updateMethod = stream.UpdateMethod
+	return nil
+}
+
+func readAll[T any](ctx context.Context, client *Client, datasetID string) ([]T, error) {
+	csv, _, err := client.Datasets.DownloadDatasetCSV(ctx, datasetID, true)
+	if err != nil {
+		return nil, err
+	}
+	var rows []T
+	if err := UnmarshalCSV(csv, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func query[T any](ctx context.Context, client *Client, datasetID, sqlQuery string) ([]T, error) {
+	result, _, err := client.Datasets.Query(ctx, datasetID, sqlQuery)
+	if err != nil {
+		return nil, err
+	}
+	var rows []T
+	dec, err := newRowDecoder(&rows, result.Columns)
+	if err != nil {
+		return nil, err
+	}
+	for i, record := range result.StringRows() {
+		if err := dec.append(record); err != nil {
+			return nil, fmt.Errorf("row %d: %v", i, err)
+		}
+	}
+	return rows, nil
+}
+
+// typeOf returns the reflect.Type of T, even when T is an interface or pointer type.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
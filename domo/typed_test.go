@@ -0,0 +1,142 @@
+package domo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type lotStatus struct {
+	Lot    string `domo:"Lot No. for Community Maps"`
+	Series int    `domo:"Sales Status Series for Community Map"`
+	Status string `domo:"Sales Status"`
+}
+
+func TestTypedDataset_Query(t *testing.T) {
+	client, server := testClientFileV2(http.StatusOK, "../test_data/datasets/datasetQueryData.json")
+	ctx := context.Background()
+	defer server.Close()
+
+	ds := NewTypedDataset[lotStatus](client, "447a2858-9c1c-42a9-b90b-a5340268d90e")
+	rows, err := ds.Query(ctx, "SELECT * FROM table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 103 {
+		t.Fatalf("Expected 103 rows, got %d", len(rows))
+	}
+	if rows[1].Lot != "Lot-87" || rows[1].Series != 5 || rows[1].Status != "Pending - EM Received" {
+		t.Errorf("Unexpected row %+v", rows[1])
+	}
+}
+
+func TestTypedDataset_ReplaceAndReadAll(t *testing.T) {
+	var uploaded string
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PUT" && r.URL.Path == "/v1/datasets/abc/data":
+			if m := r.URL.Query().Get("updateMethod"); m != UpdateMethodReplace {
+				t.Errorf("Expected update method %s, got %s", UpdateMethodReplace, m)
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			uploaded = string(b)
+		case r.Method == "GET" && r.URL.Path == "/v1/datasets/abc/data":
+			w.Write([]byte("Sales Status,Lot No. for Community Maps\nClosed,Lot-1\n"))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	ctx := context.Background()
+	defer server.Close()
+
+	ds := NewTypedDataset[lotStatus](client, "abc")
+	if _, err := ds.Replace(ctx, []lotStatus{{Lot: "Lot-1", Series: 2, Status: "Closed"}}); err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != "Lot-1,2,Closed\n" {
+		t.Errorf("Unexpected uploaded csv %q", uploaded)
+	}
+	rows, err := ds.ReadAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Lot != "Lot-1" || rows[0].Status != "Closed" {
+		t.Errorf("Unexpected rows %+v", rows)
+	}
+}
+
+func TestTypedStream_Append(t *testing.T) {
+	var requests []string
+	var parts []string
+	streamMethod := UpdateMethodReplace
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/streams/42":
+			fmt.Fprintf(w, `{"id": 42, "updateMethod": %q, "dataSet": {"id": "abc"}}`, streamMethod)
+		case r.Method == "PATCH":
+			w.Write([]byte(`{"id": 42, "updateMethod": "APPEND"}`))
+		case strings.HasSuffix(r.URL.Path, "/executions"):
+			w.Write([]byte(`{"id": 1, "currentState": "ACTIVE"}`))
+		case strings.Contains(r.URL.Path, "/part/"):
+			b, _ := ioutil.ReadAll(r.Body)
+			parts = append(parts, string(b))
+			w.Write([]byte(`{"id": 1}`))
+		case strings.HasSuffix(r.URL.Path, "/commit"):
+			w.Write([]byte(`{"id": 1, "currentState": "SUCCESS"}`))
+		}
+	})
+	ctx := context.Background()
+	defer server.Close()
+
+	stream := NewTypedStream[lotStatus](client, 42)
+	stream.PartRows = 2
+	rows := []lotStatus{{Lot: "Lot-1"}, {Lot: "Lot-2"}, {Lot: "Lot-3"}}
+	execution, err := stream.Append(ctx, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if execution.CurrentState != "SUCCESS" {
+		t.Errorf("Expected committed execution, got state %s", execution.CurrentState)
+	}
+	expectedRequests := []string{
+		"GET /v1/streams/42",
+		"PATCH /v1/streams/42",
+		"POST /v1/streams/42/executions",
+		"PUT /v1/streams/42/executions/1/part/1",
+		"PUT /v1/streams/42/executions/1/part/2",
+		"PUT /v1/streams/42/executions/1/commit",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expectedRequests, "\n") {
+		t.Errorf("Unexpected requests:\n%s", strings.Join(requests, "\n"))
+	}
+	if len(parts) != 2 || parts[1] != "Lot-3,0,\n" {
+		t.Errorf("Unexpected parts %q", parts)
+	}
+
+	// The update method is only changed once.
+	requests = nil
+	if _, err := stream.Append(ctx, rows[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if requests[0] != "POST /v1/streams/42/executions" {
+		t.Errorf("Expected the stream update method to be cached, got requests %q", requests)
+	}
+
+	// A stream already appending isn't modified.
+	streamMethod = UpdateMethodAppend
+	requests = nil
+	stream = NewTypedStream[lotStatus](client, 42)
+	if _, err := stream.Append(ctx, rows[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if requests[0] != "GET /v1/streams/42" || requests[1] != "POST /v1/streams/42/executions" {
+		t.Errorf("Expected the update method not to be changed, got requests %q", requests)
+	}
+	if stream.DatasetID != "abc" {
+		t.Errorf("Expected the dataset id to be looked up, got %q", stream.DatasetID)
+	}
+}
@@ -0,0 +1,90 @@
+package domo
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+)
+
+// UploadStats counts the bytes of a single dataset or stream data part upload.
+type UploadStats struct {
+	// URL the data was uploaded to.
+	URL string
+	// Part number for stream data parts, 0 for dataset uploads.
+	Part int
+	// RawBytes of CSV read from the source.
+	RawBytes int64
+	// SentBytes in the request body. It's the gzipped size when Compressed is set.
+	SentBytes  int64
+	Compressed bool
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func (c *countingReader) count() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+// doUpload sends a CSV upload request with body as the data, gzipping it on the fly when the client has
+// CompressUploads set. size is the length of body, or -1 if it isn't known.
+func (c *Client) doUpload(ctx context.Context, method, urlStr string, body io.Reader, size int64, part int, v interface{}) (*http.Response, error) {
+	req, err := c.NewRequest(method, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/csv")
+
+	raw := &countingReader{r: body}
+	var sent *countingReader
+	if c.CompressUploads {
+		pr, pw := io.Pipe()
+		go func() {
+			gz := gzip.NewWriter(pw)
+			_, err := io.Copy(gz, raw)
+			if err == nil {
+				err = gz.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		sent = &countingReader{r: pr}
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{sent, pr}
+		req.ContentLength = -1
+		req.Header.Set("Content-Encoding", "gzip")
+	} else {
+		sent = raw
+		req.Body = ioutil.NopCloser(sent)
+		req.ContentLength = size
+	}
+	if req.ContentLength == 0 {
+		// http.Request treats a zero ContentLength with a body as unknown.
+		req.Body = http.NoBody
+	}
+
+	resp, err := c.Do(ctx, req, v)
+	if c.OnUpload != nil {
+		c.OnUpload(UploadStats{
+			URL:        req.URL.String(),
+			Part:       part,
+			RawBytes:   raw.count(),
+			SentBytes:  sent.count(),
+			Compressed: c.CompressUploads,
+		})
+	}
+	return resp, err
+}
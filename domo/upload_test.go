@@ -0,0 +1,75 @@
+package domo
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUploadDataPartStr_Compressed(t *testing.T) {
+	csvData := strings.Repeat("Lot-1,2,Closed\n", 1000)
+	var received string
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("Expected a gzip Content-Encoding, got %q", r.Header.Get("Content-Encoding"))
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(gz)
+		received = string(b)
+		w.Write([]byte(`{"id": 1}`))
+	})
+	defer server.Close()
+	var stats []UploadStats
+	client.CompressUploads = true
+	client.OnUpload = func(s UploadStats) { stats = append(stats, s) }
+
+	if _, _, err := client.Streams.UploadDataPartStr(context.Background(), 42, 1, 3, csvData); err != nil {
+		t.Fatal(err)
+	}
+	if received != csvData {
+		t.Error("Decompressed part doesn't match the uploaded csv")
+	}
+	if len(stats) != 1 {
+		t.Fatalf("Expected 1 upload stat, got %d", len(stats))
+	}
+	s := stats[0]
+	if s.Part != 3 || !s.Compressed || s.RawBytes != int64(len(csvData)) {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	if s.SentBytes == 0 || s.SentBytes >= s.RawBytes {
+		t.Errorf("Expected the sent bytes to be compressed, got %+v", s)
+	}
+}
+
+func TestUploadDataReader_Uncompressed(t *testing.T) {
+	var received string
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Unexpected Content-Encoding %q", r.Header.Get("Content-Encoding"))
+		}
+		if r.Header.Get("Content-Type") != "text/csv" {
+			t.Errorf("Expected a text/csv Content-Type, got %q", r.Header.Get("Content-Type"))
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		received = string(b)
+	})
+	defer server.Close()
+	var stats UploadStats
+	client.OnUpload = func(s UploadStats) { stats = s }
+
+	if _, err := client.Datasets.UploadDataReader(context.Background(), "abc", strings.NewReader("a,b\n")); err != nil {
+		t.Fatal(err)
+	}
+	if received != "a,b\n" {
+		t.Errorf("Unexpected body %q", received)
+	}
+	if stats.RawBytes != 4 || stats.SentBytes != 4 || stats.Compressed {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
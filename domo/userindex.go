@@ -0,0 +1,154 @@
+package domo
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUserIndexTTL is how long a UserIndex uses the users it loaded before loading them again.
+const DefaultUserIndexTTL = 15 * time.Minute
+
+// UserIndex is an in-memory index of every Domo user, for repeated lookups by email, employee number or
+// id without paging through the users each time. The index loads the users on first use and again once
+// they're older than its TTL. Users returned are copies, so they may be modified.
+type UserIndex struct {
+	Users *UsersService
+	// TTL is how long the loaded users are used. Defaults to DefaultUserIndexTTL.
+	TTL time.Duration
+
+	mu               sync.Mutex
+	loadedAt         time.Time
+	users            []*User
+	byID             map[int]*User
+	byEmail          map[string]*User
+	byEmployeeNumber map[int]*User
+	now              func() time.Time
+}
+
+// NewUserIndex returns a UserIndex refreshing its users after ttl.
+func NewUserIndex(client *Client, ttl time.Duration) *UserIndex {
+	return &UserIndex{Users: client.Users, TTL: ttl}
+}
+
+// Refresh loads every user now.
+func (x *UserIndex) Refresh(ctx context.Context) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.refresh(ctx)
+}
+
+// Invalidate drops the loaded users, so the next lookup loads them again, i.e. after creating a user.
+func (x *UserIndex) Invalidate() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.loadedAt = time.Time{}
+}
+
+// FindByEmail returns the user with the email, compared case insensitively, or ErrUserNotFound.
+func (x *UserIndex) FindByEmail(ctx context.Context, email string) (*User, error) {
+	return x.lookup(ctx, func() *User { return x.byEmail[strings.ToLower(email)] })
+}
+
+// FindByEmployeeNumber returns the user with the employee number, or ErrUserNotFound.
+func (x *UserIndex) FindByEmployeeNumber(ctx context.Context, employeeNumber int) (*User, error) {
+	return x.lookup(ctx, func() *User { return x.byEmployeeNumber[employeeNumber] })
+}
+
+// FindByID returns the user with the id, or ErrUserNotFound.
+func (x *UserIndex) FindByID(ctx context.Context, userID int) (*User, error) {
+	return x.lookup(ctx, func() *User { return x.byID[userID] })
+}
+
+// Search returns the users match returns true for.
+func (x *UserIndex) Search(ctx context.Context, match func(u *User) bool) ([]*User, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := x.load(ctx); err != nil {
+		return nil, err
+	}
+	var found []*User
+	for _, u := range x.users {
+		if match(u) {
+			user := *u
+			found = append(found, &user)
+		}
+	}
+	return found, nil
+}
+
+// UserIDsByEmail maps the emails to user ids, i.e. for PDP policies or group members. Emails without a
+// user are returned in missing.
+func (x *UserIndex) UserIDsByEmail(ctx context.Context, emails []string) (ids map[string]int, missing []string, err error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := x.load(ctx); err != nil {
+		return nil, nil, err
+	}
+	ids = make(map[string]int, len(emails))
+	for _, email := range emails {
+		if u := x.byEmail[strings.ToLower(email)]; u != nil {
+			ids[email] = u.ID
+		} else {
+			missing = append(missing, email)
+		}
+	}
+	return ids, missing, nil
+}
+
+func (x *UserIndex) lookup(ctx context.Context, find func() *User) (*User, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := x.load(ctx); err != nil {
+		return nil, err
+	}
+	u := find()
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	user := *u
+	return &user, nil
+}
+
+// load refreshes the users if they're missing or expired. x.mu must be held.
+func (x *UserIndex) load(ctx context.Context) error {
+	ttl := x.TTL
+	if ttl <= 0 {
+		ttl = DefaultUserIndexTTL
+	}
+	if !x.loadedAt.IsZero() && x.clock().Sub(x.loadedAt) < ttl {
+		return nil
+	}
+	return x.refresh(ctx)
+}
+
+// refresh loads every user. x.mu must be held.
+func (x *UserIndex) refresh(ctx context.Context) error {
+	users, err := x.Users.AllUsers(ctx)
+	if err != nil {
+		return err
+	}
+	x.users = users
+	x.byID = make(map[int]*User, len(users))
+	x.byEmail = make(map[string]*User, len(users))
+	x.byEmployeeNumber = make(map[int]*User, len(users))
+	for _, u := range users {
+		x.byID[u.ID] = u
+		if u.Email != "" {
+			x.byEmail[strings.ToLower(u.Email)] = u
+		}
+		if u.EmployeeNumber != 0 {
+			x.byEmployeeNumber[u.EmployeeNumber] = u
+		}
+	}
+	x.loadedAt = x.clock()
+	return nil
+}
+
+func (x *UserIndex) clock() time.Time {
+	if x.now != nil {
+		return x.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,65 @@
+package domo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUserIndex(t *testing.T) {
+	requests := 0
+	users := []*User{
+		{ID: 1, Name: "Alice", Email: "Alice@example.com", EmployeeNumber: 100, Role: "Admin"},
+		{ID: 2, Name: "Bob", Email: "bob@example.com", Role: "Participant"},
+	}
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(users)
+	})
+	defer server.Close()
+	ctx := context.Background()
+
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	index := NewUserIndex(client, time.Hour)
+	index.now = func() time.Time { return now }
+
+	alice, err := index.FindByEmail(ctx, "alice@EXAMPLE.com")
+	if err != nil || alice.ID != 1 {
+		t.Fatalf("Got wrong User %+v, %v", alice, err)
+	}
+	alice.Name = "changed"
+	if u, err := index.FindByEmployeeNumber(ctx, 100); err != nil || u.Name != "Alice" {
+		t.Errorf("Got wrong User %+v, %v", u, err)
+	}
+	if u, err := index.FindByID(ctx, 2); err != nil || u.Email != "bob@example.com" {
+		t.Errorf("Got wrong User %+v, %v", u, err)
+	}
+	if _, err := index.FindByEmail(ctx, "carol@example.com"); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	admins, err := index.Search(ctx, func(u *User) bool { return u.Role == "Admin" })
+	if err != nil || len(admins) != 1 || admins[0].ID != 1 {
+		t.Errorf("Got wrong Users %+v, %v", admins, err)
+	}
+	ids, missing, err := index.UserIDsByEmail(ctx, []string{"bob@example.com", "carol@example.com"})
+	if err != nil || !reflect.DeepEqual(ids, map[string]int{"bob@example.com": 2}) || !reflect.DeepEqual(missing, []string{"carol@example.com"}) {
+		t.Errorf("Got wrong ids %v, missing %v, %v", ids, missing, err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected the users to be listed once, got %d", requests)
+	}
+
+	// the users are loaded again once they expire.
+	users = append(users, &User{ID: 3, Email: "carol@example.com"})
+	now = now.Add(2 * time.Hour)
+	if u, err := index.FindByEmail(ctx, "carol@example.com"); err != nil || u.ID != 3 {
+		t.Errorf("Got wrong User %+v, %v", u, err)
+	}
+	index.Invalidate()
+	if _, err := index.FindByID(ctx, 3); err != nil || requests != 3 {
+		t.Errorf("Expected an invalidated index to reload, got %d requests, %v", requests, err)
+	}
+}
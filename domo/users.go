@@ -2,25 +2,30 @@ package domo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
+// ErrUserNotFound is returned by user lookups that match no user.
+var ErrUserNotFound = errors.New("user not found")
 
 // User is the object for a Domo User
 type User struct {
-	ID             int    `json:"id,omitempty"`
-	Name           string `json:"name,omitempty"`
-	Role           string `json:"role,omitempty"`
-	Title          string `json:"title,omitempty"`
-	AlternateEmail string `json:"alternateEmail,omitempty"`
-	Phone          string `json:"phone,omitempty"`
-	Location       string `json:"location,omitempty"`
-	Timezone       string `json:"timezone,omitempty"`
-	ImageURI       string `json:"image,omitempty"`
-	EmployeeNumber int    `json:"employeeNumber,omitempty"`
-	CreatedAt      string `json:"createdAt,omitempty"`
-	UpdatedAt      string `json:"updatedAt,omitempty"`
+	ID             int        `json:"id,omitempty"`
+	Name           string     `json:"name,omitempty"`
+	Email          string     `json:"email,omitempty"`
+	Role           string     `json:"role,omitempty"`
+	Title          string     `json:"title,omitempty"`
+	AlternateEmail string     `json:"alternateEmail,omitempty"`
+	Phone          string     `json:"phone,omitempty"`
+	Location       string     `json:"location,omitempty"`
+	Timezone       string     `json:"timezone,omitempty"`
+	ImageURI       string     `json:"image,omitempty"`
+	EmployeeNumber int        `json:"employeeNumber,omitempty"`
+	CreatedAt      *Timestamp `json:"createdAt,omitempty"`
+	UpdatedAt      *Timestamp `json:"updatedAt,omitempty"`
 	// Groups []DomoGroup `json:"groups,omitempty"`
 }
 
@@ -56,6 +61,62 @@ func (s *UsersService) List(ctx context.Context, limit, offset int) ([]*User, *h
 	return users, resp, nil
 }
 
+// AllUsers pages through List and returns every user.
+func (s *UsersService) AllUsers(ctx context.Context) ([]*User, error) {
+	var all []*User
+	err := s.each(ctx, func(u *User) bool {
+		all = append(all, u)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// FindByEmail pages through the users until it finds the one with the email, compared case
+// insensitively. It returns ErrUserNotFound if there's none. Use a UserIndex for repeated lookups.
+func (s *UsersService) FindByEmail(ctx context.Context, email string) (*User, error) {
+	return s.find(ctx, func(u *User) bool { return strings.EqualFold(u.Email, email) })
+}
+
+// FindByEmployeeNumber pages through the users until it finds the one with the employee number. It
+// returns ErrUserNotFound if there's none. Use a UserIndex for repeated lookups.
+func (s *UsersService) FindByEmployeeNumber(ctx context.Context, employeeNumber int) (*User, error) {
+	return s.find(ctx, func(u *User) bool { return u.EmployeeNumber == employeeNumber })
+}
+
+// Search pages through every user and returns those match returns true for.
+func (s *UsersService) Search(ctx context.Context, match func(u *User) bool) ([]*User, error) {
+	var found []*User
+	err := s.each(ctx, func(u *User) bool {
+		if match(u) {
+			found = append(found, u)
+		}
+		return true
+	})
+	return found, err
+}
+
+func (s *UsersService) find(ctx context.Context, match func(u *User) bool) (*User, error) {
+	var found *User
+	err := s.each(ctx, func(u *User) bool {
+		if match(u) {
+			found = u
+		}
+		return found == nil
+	})
+	if err == nil && found == nil {
+		err = ErrUserNotFound
+	}
+	return found, err
+}
+
+// each pages through the users calling fn with each until it returns false.
+func (s *UsersService) each(ctx context.Context, fn func(u *User) bool) error {
+	return eachPaged(ctx, s.List, fn)
+}
+
 // Info for the user for the given user id.
 //
 // Domo API Docs: https://developer.domo.com/docs/users-api-reference/users-2#Retrieve%20a%20user
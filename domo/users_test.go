@@ -78,3 +78,36 @@ func TestUsersService_Delete(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUsersService_FindByEmail(t *testing.T) {
+	client, server := testClientFileV2(http.StatusOK, "../test_data/users/list_users.json")
+	defer server.Close()
+	ctx := context.Background()
+
+	user, err := client.Users.FindByEmail(ctx, "John.Nash@domo.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1845705061 {
+		t.Errorf("Got wrong User %+v", user)
+	}
+	if _, err := client.Users.FindByEmail(ctx, "nobody@domo.com"); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if _, err := client.Users.FindByEmployeeNumber(ctx, 42); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUsersService_Search(t *testing.T) {
+	client, server := testClientFileV2(http.StatusOK, "../test_data/users/list_users.json")
+	defer server.Close()
+
+	admins, err := client.Users.Search(context.Background(), func(u *User) bool { return u.Role == "Admin" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(admins) != 2 || admins[0].Name != "Hattori Hanzo" || admins[1].Name != "John Forbes Nash, Jr." {
+		t.Errorf("Got wrong Users %+v", admins)
+	}
+}
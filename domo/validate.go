@@ -0,0 +1,266 @@
+package domo
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ValidationMode sets what a CSVValidator does with rows that fail validation.
+type ValidationMode int
+
+const (
+	// ValidateReject fails the whole upload if any row is invalid.
+	ValidateReject ValidationMode = iota
+	// ValidateSkip removes invalid rows from the CSV.
+	ValidateSkip
+	// ValidateDeadLetter removes invalid rows from the CSV and writes them to the CSVValidator's DeadLetter.
+	ValidateDeadLetter
+)
+
+// CSVValidator checks CSV against a dataset's Schema before it's uploaded. Domo reports a bad
+// upload as an execution in the ERROR state without saying which rows were wrong, the validator
+// reports each bad cell instead.
+//
+// Rows must have a cell for each column. Empty cells are always valid, Domo loads them as nulls.
+// LONG, DOUBLE and DECIMAL cells must be numbers, DATE and DATETIME cells must be in one of the
+// layouts UnmarshalCSV reads. No cell may contain a newline. Cells may be quoted, with the double
+// quotes inside them doubled the way MarshalCSV writes them, but a row with unbalanced quotes is invalid.
+type CSVValidator struct {
+	Schema Schema
+	Mode   ValidationMode
+	// DeadLetter receives the invalid rows as CSV when Mode is ValidateDeadLetter.
+	DeadLetter io.Writer
+}
+
+// NewCSVValidator returns a CSVValidator for the schema.
+func NewCSVValidator(schema Schema, mode ValidationMode) *CSVValidator {
+	return &CSVValidator{Schema: schema, Mode: mode}
+}
+
+// ValidationIssue is a single invalid row or cell.
+type ValidationIssue struct {
+	// Row number in the CSV, starting at 1.
+	Row int
+	// Column name, empty for row level issues like a wrong column count.
+	Column string
+	Value  string
+	Reason string
+}
+
+func (i ValidationIssue) String() string {
+	if i.Column == "" {
+		return fmt.Sprintf("row %d: %s", i.Row, i.Reason)
+	}
+	return fmt.Sprintf("row %d column %q: %s", i.Row, i.Column, i.Reason)
+}
+
+// ValidationReport lists the issues found validating a CSV.
+type ValidationReport struct {
+	Rows        int
+	InvalidRows int
+	Issues      []ValidationIssue
+}
+
+// ValidationError is returned by a ValidateReject CSVValidator when any row is invalid.
+type ValidationError struct {
+	Report *ValidationReport
+}
+
+func (e ValidationError) Error() string {
+	msg := fmt.Sprintf("%d of %d rows failed validation", e.Report.InvalidRows, e.Report.Rows)
+	if len(e.Report.Issues) > 0 {
+		msg += ", first issue " + e.Report.Issues[0].String()
+	}
+	return msg
+}
+
+// Validate checks every row of the CSV, which must not have a header row, and returns the CSV to
+// upload. In ValidateReject mode that's the CSV unchanged and a ValidationError is returned if any
+// row is invalid. In the other modes the invalid rows are removed.
+func (v *CSVValidator) Validate(csvData string) (string, *ValidationReport, error) {
+	if v.Mode == ValidateDeadLetter && v.DeadLetter == nil {
+		return "", nil, fmt.Errorf("validation mode is dead letter but DeadLetter is nil")
+	}
+	report := &ValidationReport{}
+	valid := &strings.Builder{}
+	for _, raw := range splitCSVRecords(csvData) {
+		report.Rows++
+		issues := v.validateRaw(report.Rows, raw)
+		if len(issues) == 0 {
+			valid.WriteString(raw.line())
+			continue
+		}
+		report.InvalidRows++
+		report.Issues = append(report.Issues, issues...)
+		if v.Mode == ValidateDeadLetter {
+			if _, err := io.WriteString(v.DeadLetter, raw.line()); err != nil {
+				return "", report, err
+			}
+		}
+	}
+
+	if v.Mode == ValidateReject {
+		if report.InvalidRows > 0 {
+			return csvData, report, ValidationError{Report: report}
+		}
+		return csvData, report, nil
+	}
+	return valid.String(), report, nil
+}
+
+// validateRaw parses a raw CSV record and validates its cells.
+func (v *CSVValidator) validateRaw(row int, raw rawCSVRecord) []ValidationIssue {
+	value := strings.TrimRight(raw.text, "\r\n")
+	if !raw.balanced {
+		return []ValidationIssue{{Row: row, Value: value, Reason: "unbalanced double quotes"}}
+	}
+	r := csv.NewReader(strings.NewReader(raw.text))
+	r.FieldsPerRecord = -1
+	record, err := r.Read()
+	if err != nil {
+		return []ValidationIssue{{Row: row, Value: value, Reason: err.Error()}}
+	}
+	return v.validateRecord(row, record)
+}
+
+func (v *CSVValidator) validateRecord(row int, record []string) []ValidationIssue {
+	columns := v.Schema.Columns
+	if len(record) != len(columns) {
+		return []ValidationIssue{{Row: row, Reason: fmt.Sprintf("expected %d columns but got %d", len(columns), len(record))}}
+	}
+	var issues []ValidationIssue
+	for i, cell := range record {
+		if reason := validateCell(columns[i].ColumnType, cell); reason != "" {
+			issues = append(issues, ValidationIssue{Row: row, Column: columns[i].Name, Value: cell, Reason: reason})
+		}
+	}
+	return issues
+}
+
+// validateCell returns why the cell isn't valid for the column type, or an empty string.
+func validateCell(columnType, cell string) string {
+	if strings.ContainsAny(cell, "\r\n") {
+		return "contains a newline"
+	}
+	if cell == "" {
+		return ""
+	}
+	switch columnType {
+	case ColumnTypeLong:
+		if _, err := strconv.ParseInt(cell, 10, 64); err != nil {
+			return "not a LONG"
+		}
+	case ColumnTypeDouble, ColumnTypeDecimal:
+		if _, err := strconv.ParseFloat(cell, 64); err != nil {
+			return "not a " + columnType
+		}
+	case ColumnTypeDate, ColumnTypeDatetime:
+		if _, err := parseCSVTime(cell); err != nil {
+			return "not a " + columnType
+		}
+	}
+	return ""
+}
+
+// rawCSVRecord is a single record of CSV as it was written.
+type rawCSVRecord struct {
+	text string
+	// balanced is false when a quote is missing or misplaced, i.e. a quote in an unquoted cell.
+	balanced bool
+}
+
+// line returns the record's text ending in a newline.
+func (r rawCSVRecord) line() string {
+	if strings.HasSuffix(r.text, "\n") {
+		return r.text
+	}
+	return r.text + "\n"
+}
+
+// splitCSVRecords splits CSV data into its records without parsing the cells, so a record with
+// unbalanced quotes only affects itself. Quoted cells may span lines. Empty lines are skipped.
+func splitCSVRecords(data string) []rawCSVRecord {
+	const (
+		fieldStart = iota
+		unquoted
+		quoted
+		quoteInQuoted
+	)
+	var records []rawCSVRecord
+	start, state, balanced := 0, fieldStart, true
+	emit := func(end int) {
+		if text := data[start:end]; strings.TrimRight(text, "\r\n") != "" {
+			records = append(records, rawCSVRecord{text: text, balanced: balanced})
+		}
+		start, state, balanced = end, fieldStart, true
+	}
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch state {
+		case fieldStart, unquoted:
+			switch {
+			case c == '"' && state == fieldStart:
+				state = quoted
+			case c == '"':
+				balanced = false
+			case c == ',':
+				state = fieldStart
+			case c == '\n':
+				emit(i + 1)
+			default:
+				state = unquoted
+			}
+		case quoted:
+			if c == '"' {
+				state = quoteInQuoted
+			}
+		case quoteInQuoted:
+			switch {
+			case c == '"':
+				state = quoted
+			case c == ',':
+				state = fieldStart
+			case c == '\n':
+				emit(i + 1)
+			case c == '\r' && i+1 < len(data) && data[i+1] == '\n':
+			default:
+				balanced = false
+				state = unquoted
+			}
+		}
+	}
+	if state == quoted {
+		balanced = false
+	}
+	emit(len(data))
+	return records
+}
+
+// Validator returns a CSVValidator for the dataset's current schema.
+func (s *DatasetsService) Validator(ctx context.Context, id string, mode ValidationMode) (*CSVValidator, error) {
+	ds, _, err := s.Info(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return NewCSVValidator(ds.Schema, mode), nil
+}
+
+// Validator returns a CSVValidator for the current schema of the stream's dataset.
+func (s *StreamsService) Validator(ctx context.Context, streamID int, mode ValidationMode) (*CSVValidator, error) {
+	stream, _, err := s.Info(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+	if stream.Dataset == nil {
+		return nil, fmt.Errorf("stream %d has no dataset", streamID)
+	}
+	if len(stream.Dataset.Schema.Columns) > 0 {
+		return NewCSVValidator(stream.Dataset.Schema, mode), nil
+	}
+	// the stream only includes a summary of its dataset.
+	return s.client.Datasets.Validator(ctx, stream.Dataset.ID, mode)
+}
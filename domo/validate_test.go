@@ -0,0 +1,165 @@
+package domo
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+var validateTestSchema = Schema{Columns: []Column{
+	{ColumnType: ColumnTypeString, Name: "name"},
+	{ColumnType: ColumnTypeLong, Name: "count"},
+	{ColumnType: ColumnTypeDouble, Name: "price"},
+	{ColumnType: ColumnTypeDate, Name: "day"},
+	{ColumnType: ColumnTypeDatetime, Name: "at"},
+}}
+
+const validateTestCSV = `a,1,1.5,2019-03-04,2019-03-04T15:04:05Z
+b,x,1.5,2019-03-04,2019-03-04T15:04:05Z
+c,,,,
+d,1,1.5
+"e
+f",1,nope,03/04/2019,2019-03-04T15:04:05Z
+g,2,2,2019-03-05,2019-03-05 01:02:03
+`
+
+func TestCSVValidator_Reject(t *testing.T) {
+	v := NewCSVValidator(validateTestSchema, ValidateReject)
+	out, report, err := v.Validate(validateTestCSV)
+	if _, ok := err.(ValidationError); !ok {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	if out != validateTestCSV {
+		t.Errorf("Expected the csv to be returned unchanged, got %q", out)
+	}
+	if report.Rows != 6 || report.InvalidRows != 3 {
+		t.Errorf("Expected 3 of 6 rows invalid, got %d of %d", report.InvalidRows, report.Rows)
+	}
+	expected := []string{
+		`row 2 column "count": not a LONG`,
+		`row 4: expected 5 columns but got 3`,
+		`row 5 column "name": contains a newline`,
+		`row 5 column "price": not a DOUBLE`,
+		`row 5 column "day": not a DATE`,
+	}
+	if len(report.Issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %v", len(expected), report.Issues)
+	}
+	for i, issue := range report.Issues {
+		if issue.String() != expected[i] {
+			t.Errorf("Expected issue %q, got %q", expected[i], issue.String())
+		}
+	}
+	if _, _, err := v.Validate("a,1,1.5,2019-03-04,\n"); err != nil {
+		t.Errorf("Expected a valid csv to pass, got %v", err)
+	}
+}
+
+func TestCSVValidator_Skip(t *testing.T) {
+	v := NewCSVValidator(validateTestSchema, ValidateSkip)
+	out, _, err := v.Validate(validateTestCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "a,1,1.5,2019-03-04,2019-03-04T15:04:05Z\nc,,,,\ng,2,2,2019-03-05,2019-03-05 01:02:03\n"
+	if out != expected {
+		t.Errorf("Unexpected csv %q", out)
+	}
+}
+
+func TestCSVValidator_DeadLetter(t *testing.T) {
+	dead := &bytes.Buffer{}
+	v := NewCSVValidator(validateTestSchema, ValidateDeadLetter)
+	v.DeadLetter = dead
+	if _, _, err := v.Validate(`a,1,1.5,2019-03-04,"say ""hi"""` + "\n"); err != nil {
+		t.Fatal(err)
+	}
+	if dead.String() != `a,1,1.5,2019-03-04,"say ""hi"""`+"\n" {
+		t.Errorf("Unexpected dead letter csv %q", dead.String())
+	}
+
+	v.DeadLetter = nil
+	if _, _, err := v.Validate(validateTestCSV); err == nil {
+		t.Error("Expected an error without a DeadLetter writer")
+	}
+}
+
+func TestCSVValidator_Quotes(t *testing.T) {
+	schema := Schema{Columns: []Column{
+		{ColumnType: ColumnTypeString, Name: "name"},
+		{ColumnType: ColumnTypeLong, Name: "count"},
+	}}
+	v := NewCSVValidator(schema, ValidateSkip)
+	data := "a,1\n" +
+		"\"b, \"\"quoted\"\"\",2\n" +
+		"c\"d,3\n" +
+		"\"e\"f,4\n" +
+		"g,5\n" +
+		"\"h,6\n"
+	out, report, err := v.Validate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "a,1\n\"b, \"\"quoted\"\"\",2\ng,5\n" {
+		t.Errorf("Unexpected csv %q", out)
+	}
+	if report.Rows != 6 || report.InvalidRows != 3 {
+		t.Fatalf("Expected 3 of 6 rows invalid, got %d of %d: %v", report.InvalidRows, report.Rows, report.Issues)
+	}
+	for i, row := range []int{3, 4, 6} {
+		if issue := report.Issues[i]; issue.Row != row || issue.Reason != "unbalanced double quotes" {
+			t.Errorf("Expected row %d to have unbalanced quotes, got %v", row, issue)
+		}
+	}
+}
+
+func TestCSVValidator_MarshalCSV(t *testing.T) {
+	type quotedRow struct {
+		Name  string   `domo:"name"`
+		Tags  []string `domo:"tags"`
+		Count int      `domo:"count"`
+	}
+	rows := []quotedRow{
+		{Name: `say "hi"`, Tags: []string{"a", "b"}, Count: 1},
+		{Name: "x, y", Count: 2},
+	}
+	data, err := MarshalCSV(rows, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := GenerateDataSetSchema(reflect.TypeOf(quotedRow{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, report, err := NewCSVValidator(schema, ValidateReject).Validate(data)
+	if err != nil {
+		t.Fatalf("Expected MarshalCSV output to be valid, got %v", err)
+	}
+	if out != data || report.Rows != 2 {
+		t.Errorf("Unexpected validation of %q: %q %+v", data, out, report)
+	}
+}
+
+func TestStreamsService_Validator(t *testing.T) {
+	client, server := testClientHandlerV2(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/streams/42":
+			http.ServeFile(w, r, "../test_data/streams/get_stream_details.json")
+		case "/v1/datasets/0c1e0dbe-9f71-4625-9b50-b79e6e4266f2":
+			w.Write([]byte(`{"id": "0c1e0dbe-9f71-4625-9b50-b79e6e4266f2", "schema": {"columns": [{"type": "LONG", "name": "count"}]}}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer server.Close()
+
+	v, err := client.Streams.Validator(context.Background(), 42, ValidateReject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Schema.Columns) != 1 || v.Schema.Columns[0].Name != "count" {
+		t.Errorf("Unexpected schema %+v", v.Schema)
+	}
+}
@@ -1,10 +1,11 @@
 module github.com/BuildIntelligence/domo-gopher/v2
 
+require golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
+
 require (
 	github.com/golang/protobuf v1.3.2 // indirect
 	golang.org/x/net v0.0.0-20200114155413-6afb5195e5aa // indirect
-	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	google.golang.org/appengine v1.6.5 // indirect
 )
 
-go 1.13
+go 1.18